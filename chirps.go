@@ -1,20 +1,47 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
+	"time"
 
 	"github.com/A-X-Z-Y-T-E/Chirpy/internal/auth"
+	"github.com/A-X-Z-Y-T-E/Chirpy/internal/contentfilter"
 	"github.com/A-X-Z-Y-T-E/Chirpy/internal/database"
+	"github.com/A-X-Z-Y-T-E/Chirpy/internal/entitlements"
+	"github.com/A-X-Z-Y-T-E/Chirpy/internal/pagination"
+	"github.com/A-X-Z-Y-T-E/Chirpy/internal/textparse"
 	"github.com/google/uuid"
 )
 
 type Chirp struct {
-	ID        string `json:"id"`
-	CreatedAt string `json:"created_at"`
-	UpdatedAt string `json:"updated_at"`
-	Body      string `json:"body"`
-	User_id   string `json:"user_id"`
+	ID          string         `json:"id"`
+	CreatedAt   string         `json:"created_at"`
+	UpdatedAt   string         `json:"updated_at"`
+	Body        string         `json:"body"`
+	User_id     string         `json:"user_id"`
+	In_reply_to string         `json:"in_reply_to,omitempty"`
+	Like_count  int64          `json:"like_count"`
+	Liked_by_me *bool          `json:"liked_by_me,omitempty"`
+	Mentions    []ChirpMention `json:"mentions,omitempty"`
+	Media       []ChirpMedia   `json:"media,omitempty"`
+}
+
+func chirpJSON(chirp database.Chirp) Chirp {
+	out := Chirp{
+		ID:        chirp.ID.String(),
+		CreatedAt: chirp.CreatedAt.String(),
+		UpdatedAt: chirp.UpdatedAt.String(),
+		Body:      chirp.Body,
+		User_id:   chirp.UserID.String(),
+	}
+	if chirp.InReplyTo.Valid {
+		out.In_reply_to = chirp.InReplyTo.UUID.String()
+	}
+	return out
 }
 
 func convert_to_uuid(user_id string) (uuid.UUID, error) {
@@ -24,37 +51,171 @@ func convert_to_uuid(user_id string) (uuid.UUID, error) {
 	}
 	return User_id, nil
 }
+
+// decorateChirps fills in the parts of a chirp payload that live in other
+// tables, with one query per table for the whole batch.
+func (cfg *apiConfig) decorateChirps(ctx context.Context, viewer uuid.NullUUID, chirps map[uuid.UUID]*Chirp) error {
+	ids := make([]uuid.UUID, 0, len(chirps))
+	for id := range chirps {
+		ids = append(ids, id)
+	}
+	stats, err := cfg.likeStats(ctx, viewer, ids)
+	if err != nil {
+		return err
+	}
+	mentions, err := cfg.db.GetChirpMentions(ctx, ids)
+	if err != nil {
+		return err
+	}
+	media, err := cfg.db.GetChirpMedia(ctx, ids)
+	if err != nil {
+		return err
+	}
+	for id, chirp := range chirps {
+		applyLikeStats(chirp, viewer, stats[id])
+	}
+	for _, medium := range media {
+		chirp := chirps[medium.ChirpID]
+		chirp.Media = append(chirp.Media, cfg.mediaJSON(medium))
+	}
+	for _, mention := range mentions {
+		chirp := chirps[mention.ChirpID]
+		chirp.Mentions = append(chirp.Mentions, ChirpMention{
+			User_id: mention.UserID.String(),
+			Handle:  mention.Handle,
+		})
+	}
+	return nil
+}
+
+// chirpsJSON converts a page of chirps into their decorated payloads.
+func (cfg *apiConfig) chirpsJSON(ctx context.Context, viewer uuid.NullUUID, chirps []database.Chirp) ([]Chirp, error) {
+	out := make([]Chirp, len(chirps))
+	byID := make(map[uuid.UUID]*Chirp, len(chirps))
+	for i, chirp := range chirps {
+		out[i] = chirpJSON(chirp)
+		byID[chirp.ID] = &out[i]
+	}
+	if err := cfg.decorateChirps(ctx, viewer, byID); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// checkChirpBody validates a new chirp body against the author's plan and
+// runs it through the content filter. It writes the error response itself
+// and reports whether the handler should carry on with the returned body.
+func (cfg *apiConfig) checkChirpBody(w http.ResponseWriter, body string, ent entitlements.Entitlements) (string, bool) {
+	if err := ent.CheckChirpLength(len(body)); err != nil {
+		writeEntitlementError(w, err)
+		return "", false
+	}
+	filtered, err := cfg.contentFilter.Apply(body)
+	var rejection *contentfilter.Rejection
+	if errors.As(err, &rejection) {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(struct {
+			Error string `json:"error"`
+			Code  string `json:"code"`
+			Rule  string `json:"rule"`
+		}{
+			Error: rejection.Message,
+			Code:  "content_rejected",
+			Rule:  rejection.Rule,
+		})
+		return "", false
+	}
+	if err != nil {
+		fmt.Println("content filter error:", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(struct {
+			Error string `json:"error"`
+		}{
+			Error: "Something went wrong",
+		})
+		return "", false
+	}
+	return filtered, true
+}
+
+// indexChirp records the hashtags and mentions in a chirp body, replacing
+// any recorded for an earlier version of the chirp. Hashtags the chirp
+// already had keep their original created_at, so editing an old chirp does
+// not make its tags trend again. Failures are logged rather than returned
+// because the chirp itself is already saved.
+func (cfg *apiConfig) indexChirp(ctx context.Context, chirp database.Chirp) {
+	tags := textparse.ExtractHashtags(chirp.Body)
+	err := cfg.db.RemoveStaleChirpHashtags(ctx, database.RemoveStaleChirpHashtagsParams{
+		ChirpID: chirp.ID,
+		Tags:    tags,
+	})
+	if err != nil {
+		fmt.Println("RemoveStaleChirpHashtags error:", err)
+	}
+	if len(tags) > 0 {
+		err := cfg.db.AddChirpHashtags(ctx, database.AddChirpHashtagsParams{
+			Tags:    tags,
+			ChirpID: chirp.ID,
+		})
+		if err != nil {
+			fmt.Println("AddChirpHashtags error:", err)
+		}
+	}
+
+	if err := cfg.db.ClearChirpMentions(ctx, chirp.ID); err != nil {
+		fmt.Println("ClearChirpMentions error:", err)
+	}
+	if handles := textparse.ExtractMentions(chirp.Body); len(handles) > 0 {
+		err := cfg.db.AddChirpMentions(ctx, database.AddChirpMentionsParams{
+			ChirpID: chirp.ID,
+			Handles: handles,
+		})
+		if err != nil {
+			fmt.Println("AddChirpMentions error:", err)
+		}
+	}
+}
+
+// parseAuthorID reads the optional author_id filter shared by chirp listings.
+func parseAuthorID(authorID string) (uuid.NullUUID, error) {
+	if authorID == "" {
+		return uuid.NullUUID{}, nil
+	}
+	userID, err := convert_to_uuid(authorID)
+	if err != nil {
+		return uuid.NullUUID{}, err
+	}
+	return uuid.NullUUID{UUID: userID, Valid: true}, nil
+}
+
 func (cfg *apiConfig) add_chirp() http.Handler {
 	return http.HandlerFunc(func(resW http.ResponseWriter, req *http.Request) {
 		resW.Header().Set("Content-Type", "application/json")
 
-		bearer_token, err := auth.GetBearerToken(req.Header)
-		if err != nil {
-			resW.WriteHeader(http.StatusUnauthorized)
+		user, _ := auth.UserFromContext(req.Context())
+		User_id := user.ID
+		if cfg.requireVerifiedEmail && !user.EmailVerifiedAt.Valid {
+			resW.WriteHeader(http.StatusForbidden)
 			json.NewEncoder(resW).Encode(struct {
 				Error string `json:"error"`
+				Code  string `json:"code"`
 			}{
-				Error: "Unauthorized",
+				Error: "Verify your email address before posting",
+				Code:  "email_unverified",
 			})
 			return
 		}
-		User_id, err := auth.ValidateJWT(bearer_token, cfg.secret)
-		if err != nil {
-			resW.WriteHeader(http.StatusUnauthorized)
-			json.NewEncoder(resW).Encode(struct {
-				Error string `json:"error"`
-			}{
-				Error: "Unauthorized",
-			})
+		if !cfg.checkChirpRate(resW, req, user) {
 			return
 		}
 
 		type parameters struct {
-			Body string `json:"body"`
+			Body      string `json:"body"`
+			InReplyTo string `json:"in_reply_to"`
 			// User_id string `json:"user_id"`
 		}
 		params := parameters{}
-		err = json.NewDecoder(req.Body).Decode(&params)
+		err := json.NewDecoder(req.Body).Decode(&params)
 
 		if err != nil {
 			resW.WriteHeader(http.StatusInternalServerError)
@@ -67,14 +228,8 @@ func (cfg *apiConfig) add_chirp() http.Handler {
 			return
 		}
 
-		if len(params.Body) > 140 {
-			resW.WriteHeader(http.StatusBadRequest)
-			data, _ := json.Marshal(struct {
-				Error string `json:"error"`
-			}{
-				Error: "Chirp is too long",
-			})
-			resW.Write(data)
+		body, ok := cfg.checkChirpBody(resW, params.Body, entitlements.For(user.IsChirpyRed))
+		if !ok {
 			return
 		}
 		// Convert string to UUID
@@ -88,76 +243,183 @@ func (cfg *apiConfig) add_chirp() http.Handler {
 		// 		Error: "Invalid user ID",
 		// 	})
 		// }
-		chirp, err := cfg.db.CreateChirp(req.Context(), database.CreateChirpParams{
-			Body:   params.Body,
-			UserID: User_id,
+
+		var inReplyTo uuid.NullUUID
+		replyPath := []uuid.UUID{}
+		if params.InReplyTo != "" {
+			parentID, err := convert_to_uuid(params.InReplyTo)
+			if err != nil {
+				resW.WriteHeader(http.StatusBadRequest)
+				json.NewEncoder(resW).Encode(struct {
+					Error string `json:"error"`
+				}{
+					Error: "Invalid in_reply_to",
+				})
+				return
+			}
+			parent, err := cfg.db.GetChirpByID(req.Context(), parentID)
+			if err != nil {
+				resW.WriteHeader(http.StatusNotFound)
+				json.NewEncoder(resW).Encode(struct {
+					Error string `json:"error"`
+				}{
+					Error: "Parent chirp not found",
+				})
+				return
+			}
+			inReplyTo = uuid.NullUUID{UUID: parent.ID, Valid: true}
+			replyPath = append(append(replyPath, parent.ReplyPath...), parent.ID)
+		}
+
+		chirp, recent, err := cfg.createChirp(req.Context(), user, database.CreateChirpParams{
+			Body:      body,
+			UserID:    User_id,
+			InReplyTo: inReplyTo,
+			ReplyPath: replyPath,
 		})
+		var denied *entitlements.Denied
+		if errors.As(err, &denied) {
+			writeChirpRateLimited(resW, err, recent.Oldest)
+			return
+		}
+		if err != nil {
+			resW.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(resW).Encode(struct {
+				Error string `json:"error"`
+			}{
+				Error: "Something went wrong",
+			})
+			return
+		}
+
+		cfg.indexChirp(req.Context(), chirp)
+		cfg.emitEvent(req.Context(), eventChirpCreated, chirpJSON(chirp))
 
+		out, err := cfg.chirpsJSON(req.Context(), uuid.NullUUID{UUID: User_id, Valid: true}, []database.Chirp{chirp})
+		if err != nil {
+			resW.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(resW).Encode(struct {
+				Error string `json:"error"`
+			}{
+				Error: "Something went wrong",
+			})
+			return
+		}
 		resW.WriteHeader(http.StatusCreated)
-		json.NewEncoder(resW).Encode(Chirp{
-			ID:        chirp.ID.String(),
-			CreatedAt: chirp.CreatedAt.String(),
-			UpdatedAt: chirp.UpdatedAt.String(),
-			Body:      chirp.Body,
-			User_id:   chirp.UserID.String(),
-		})
+		json.NewEncoder(resW).Encode(out[0])
+	})
+}
+
+// createChirp posts a chirp while holding a lock on its author, so
+// concurrent posts cannot take the author past the plan's rate limit. On a
+// denial it also returns the count the limit was checked against.
+func (cfg *apiConfig) createChirp(ctx context.Context, user database.User, arg database.CreateChirpParams) (database.Chirp, database.CountRecentChirpsRow, error) {
+	tx, err := cfg.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return database.Chirp{}, database.CountRecentChirpsRow{}, err
+	}
+	defer tx.Rollback()
+	q := cfg.db.WithTx(tx)
+
+	if _, err := q.LockUser(ctx, user.ID); err != nil {
+		return database.Chirp{}, database.CountRecentChirpsRow{}, err
+	}
+	recent, err := q.CountRecentChirps(ctx, database.CountRecentChirpsParams{
+		UserID:    user.ID,
+		CreatedAt: time.Now().UTC().Add(-entitlements.RateWindow),
 	})
+	if err != nil {
+		return database.Chirp{}, database.CountRecentChirpsRow{}, err
+	}
+	if err := entitlements.For(user.IsChirpyRed).CheckChirpRate(int(recent.Count)); err != nil {
+		return database.Chirp{}, recent, err
+	}
+	chirp, err := q.CreateChirp(ctx, arg)
+	if err != nil {
+		return database.Chirp{}, database.CountRecentChirpsRow{}, err
+	}
+	return chirp, recent, tx.Commit()
+}
+
+type chirpPage struct {
+	Chirps     []Chirp `json:"chirps"`
+	NextCursor string  `json:"next_cursor,omitempty"`
 }
 
 func (cfg *apiConfig) ReturnChirps() http.Handler {
 	return http.HandlerFunc(func(resW http.ResponseWriter, req *http.Request) {
 		resW.Header().Set("Content-Type", "application/json")
 
-		authorID := req.URL.Query().Get("author_id")
 		sort := req.URL.Query().Get("sort")
-		var chirps []database.Chirp
-		var err error
-		if authorID != "" {
-			userID, err := convert_to_uuid(authorID)
-			if err != nil {
-				resW.WriteHeader(http.StatusBadRequest)
-				json.NewEncoder(resW).Encode(struct {
-					Error string `json:"error"`
-				}{
-					Error: "Invalid author_id",
-				})
-				return
-			}
-			chirps, err = cfg.db.GetChirpByUserID(req.Context(), userID)
-			// chirps = []database.Chirp{chirp}
-		} else {
-			chirps, err = cfg.db.ReturnChirps(req.Context())
+
+		author, err := parseAuthorID(req.URL.Query().Get("author_id"))
+		if err != nil {
+			resW.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(resW).Encode(struct {
+				Error string `json:"error"`
+			}{
+				Error: "Invalid author_id",
+			})
+			return
 		}
 
+		page, err := pagination.FromQuery(req.URL.Query())
 		if err != nil {
 			resW.WriteHeader(http.StatusBadRequest)
 			json.NewEncoder(resW).Encode(struct {
 				Error string `json:"error"`
 			}{
-				Error: "Internal Server Error.",
+				Error: err.Error(),
 			})
 			return
 		}
 
+		// Fetch one extra row to know whether there is a next page.
+		var chirps []database.Chirp
 		if sort == "desc" {
-			for i := len(chirps)/2 - 1; i >= 0; i-- {
-				opp := len(chirps) - 1 - i
-				chirps[i], chirps[opp] = chirps[opp], chirps[i]
-			}
+			chirps, err = cfg.db.ListChirpsDesc(req.Context(), database.ListChirpsDescParams{
+				AuthorID:       author,
+				AfterCreatedAt: page.AfterCreatedAt,
+				AfterID:        page.AfterID,
+				PageLimit:      page.Limit + 1,
+			})
+		} else {
+			chirps, err = cfg.db.ListChirpsAsc(req.Context(), database.ListChirpsAscParams{
+				AuthorID:       author,
+				AfterCreatedAt: page.AfterCreatedAt,
+				AfterID:        page.AfterID,
+				PageLimit:      page.Limit + 1,
+			})
+		}
+
+		if err != nil {
+			resW.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(resW).Encode(struct {
+				Error string `json:"error"`
+			}{
+				Error: "Internal Server Error.",
+			})
+			return
 		}
 
-		chirps_out := []Chirp{}
-		for _, chirp := range chirps {
-			chirps_out = append(chirps_out, Chirp{
-				ID:        chirp.ID.String(),
-				CreatedAt: chirp.CreatedAt.String(),
-				UpdatedAt: chirp.UpdatedAt.String(),
-				Body:      chirp.Body,
-				User_id:   chirp.UserID.String(),
+		out := chirpPage{}
+		if len(chirps) > int(page.Limit) {
+			chirps = chirps[:page.Limit]
+			last := chirps[len(chirps)-1]
+			out.NextCursor = pagination.EncodeCursor(last.CreatedAt, last.ID)
+		}
+		out.Chirps, err = cfg.chirpsJSON(req.Context(), cfg.viewerID(req), chirps)
+		if err != nil {
+			resW.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(resW).Encode(struct {
+				Error string `json:"error"`
+			}{
+				Error: "Internal Server Error.",
 			})
+			return
 		}
 		resW.WriteHeader(http.StatusOK)
-		json.NewEncoder(resW).Encode(chirps_out)
+		json.NewEncoder(resW).Encode(out)
 	})
 }
 
@@ -187,13 +449,17 @@ func (cfg *apiConfig) GetChirp() http.Handler {
 			})
 			return
 		}
+		out, err := cfg.chirpsJSON(req.Context(), cfg.viewerID(req), []database.Chirp{chirp})
+		if err != nil {
+			resW.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(resW).Encode(struct {
+				Error string `json:"error"`
+			}{
+				Error: "Internal Server Error.",
+			})
+			return
+		}
 		resW.WriteHeader(http.StatusOK)
-		json.NewEncoder(resW).Encode(Chirp{
-			ID:        chirp.ID.String(),
-			CreatedAt: chirp.CreatedAt.String(),
-			UpdatedAt: chirp.UpdatedAt.String(),
-			Body:      chirp.Body,
-			User_id:   chirp.UserID.String(),
-		})
+		json.NewEncoder(resW).Encode(out[0])
 	})
 }
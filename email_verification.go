@@ -0,0 +1,170 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/A-X-Z-Y-T-E/Chirpy/internal/auth"
+	"github.com/A-X-Z-Y-T-E/Chirpy/internal/database"
+	"github.com/A-X-Z-Y-T-E/Chirpy/internal/lockout"
+	"github.com/A-X-Z-Y-T-E/Chirpy/internal/mailer"
+	"github.com/google/uuid"
+)
+
+const emailVerificationTTL = 24 * time.Hour
+
+var errVerificationThrottled = errors.New("verification email sent too recently")
+
+// sendEmailVerification mails a confirmation link to email. The token is
+// bound to that address, and VerifyUserEmail only accepts it while the
+// address is still the one waiting to be verified. Changing the pending
+// email also deletes the links sent for earlier ones. Each account gets at
+// most one mail a minute; errVerificationThrottled means this one was not
+// sent.
+func (cfg *apiConfig) sendEmailVerification(ctx context.Context, userID uuid.UUID, email string) error {
+	_, err := cfg.recordLoginAttempt(ctx, throttle{key: verificationThrottleKey(userID), policy: lockout.VerificationMail})
+	if errors.Is(err, sql.ErrNoRows) {
+		return errVerificationThrottled
+	}
+	if err != nil {
+		fmt.Println("recordLoginAttempt error:", err)
+	}
+	token, tokenHash, err := auth.MakeSingleUseToken()
+	if err != nil {
+		return err
+	}
+	err = cfg.db.CreateEmailVerification(ctx, database.CreateEmailVerificationParams{
+		TokenHash: tokenHash,
+		UserID:    userID,
+		Email:     email,
+		ExpiresAt: time.Now().Add(emailVerificationTTL),
+	})
+	if err != nil {
+		return err
+	}
+	cfg.sendMail(mailer.Message{
+		To:      email,
+		Subject: "Confirm your email for Chirpy",
+		Body: fmt.Sprintf("Follow this link within %v to confirm this address for your Chirpy account:\n"+
+			"%s/app/verify-email?token=%s\n\n"+
+			"If you didn't sign up for Chirpy, you can ignore this email.\n",
+			emailVerificationTTL, cfg.baseURL, url.QueryEscape(token)),
+	})
+	return nil
+}
+
+func (cfg *apiConfig) VerifyEmail() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		type parameters struct {
+			Token string `json:"token"`
+		}
+		var params parameters
+		err := json.NewDecoder(r.Body).Decode(&params)
+		if err != nil || params.Token == "" {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(struct {
+				Error string `json:"error"`
+			}{
+				Error: "Invalid request body",
+			})
+			return
+		}
+
+		verification, err := cfg.db.ConsumeEmailVerification(r.Context(), auth.HashToken(params.Token))
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(struct {
+				Error string `json:"error"`
+			}{
+				Error: "Invalid or expired token",
+			})
+			return
+		}
+		user, err := cfg.db.VerifyUserEmail(r.Context(), database.VerifyUserEmailParams{
+			ID:    verification.UserID,
+			Email: verification.Email,
+		})
+		if errors.Is(err, sql.ErrNoRows) {
+			// The user has asked for a different address since.
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(struct {
+				Error string `json:"error"`
+			}{
+				Error: "Invalid or expired token",
+			})
+			return
+		}
+		if isUniqueViolation(err) {
+			// Someone else claimed the address after the link was sent.
+			w.WriteHeader(http.StatusConflict)
+			json.NewEncoder(w).Encode(struct {
+				Error string `json:"error"`
+			}{
+				Error: "Email is already in use",
+			})
+			return
+		}
+		if err != nil {
+			fmt.Println("VerifyUserEmail error:", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(struct {
+				Error string `json:"error"`
+			}{
+				Error: "Something went wrong",
+			})
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(userJSON(user))
+	})
+}
+
+// ResendVerification sends a fresh link for the pending email, or for the
+// current one if it was never verified. It answers 429 within a minute of
+// the last link.
+func (cfg *apiConfig) ResendVerification() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		user, _ := auth.UserFromContext(r.Context())
+		email := user.PendingEmail.String
+		if email == "" && !user.EmailVerifiedAt.Valid {
+			email = user.Email
+		}
+		if email == "" {
+			w.WriteHeader(http.StatusConflict)
+			json.NewEncoder(w).Encode(struct {
+				Error string `json:"error"`
+			}{
+				Error: "Email is already verified",
+			})
+			return
+		}
+		err := cfg.sendEmailVerification(r.Context(), user.ID, email)
+		if errors.Is(err, errVerificationThrottled) {
+			if !cfg.throttled(w, r, "A verification email was sent recently", verificationThrottleKey(user.ID)) {
+				w.WriteHeader(http.StatusTooManyRequests)
+			}
+			return
+		}
+		if err != nil {
+			fmt.Println("sendEmailVerification error:", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(struct {
+				Error string `json:"error"`
+			}{
+				Error: "Something went wrong",
+			})
+			return
+		}
+		w.WriteHeader(http.StatusAccepted)
+	})
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/A-X-Z-Y-T-E/Chirpy/internal/database"
+	"github.com/A-X-Z-Y-T-E/Chirpy/internal/entitlements"
+	"github.com/A-X-Z-Y-T-E/Chirpy/internal/lockout"
+)
+
+// writeEntitlementError answers a request that an entitlements check
+// refused. A 402 with code "chirpy_red_required" means upgrading would
+// allow it; otherwise the limit applies to every plan.
+func writeEntitlementError(w http.ResponseWriter, err error) {
+	var denied *entitlements.Denied
+	if !errors.As(err, &denied) {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(struct {
+			Error string `json:"error"`
+		}{
+			Error: "Something went wrong",
+		})
+		return
+	}
+	if denied.RedAllows {
+		w.WriteHeader(http.StatusPaymentRequired)
+		json.NewEncoder(w).Encode(struct {
+			Error   string `json:"error"`
+			Code    string `json:"code"`
+			Feature string `json:"feature"`
+		}{
+			Error:   denied.Error(),
+			Code:    "chirpy_red_required",
+			Feature: string(denied.Feature),
+		})
+		return
+	}
+	if denied.Feature == entitlements.FeatureEditWindow {
+		w.WriteHeader(http.StatusForbidden)
+		json.NewEncoder(w).Encode(struct {
+			Error string `json:"error"`
+			Code  string `json:"code"`
+		}{
+			Error: denied.Error(),
+			Code:  "edit_window_closed",
+		})
+		return
+	}
+	w.WriteHeader(http.StatusBadRequest)
+	json.NewEncoder(w).Encode(struct {
+		Error string `json:"error"`
+	}{
+		Error: denied.Error(),
+	})
+}
+
+// checkChirpRate enforces the user's plan limit on chirps per
+// entitlements.RateWindow. It writes the 429 itself and reports whether the
+// handler should carry on. createChirp checks again under a lock; this
+// only turns a doomed request away early.
+func (cfg *apiConfig) checkChirpRate(w http.ResponseWriter, r *http.Request, user database.User) bool {
+	recent, err := cfg.db.CountRecentChirps(r.Context(), database.CountRecentChirpsParams{
+		UserID:    user.ID,
+		CreatedAt: time.Now().UTC().Add(-entitlements.RateWindow),
+	})
+	if err != nil {
+		fmt.Println("CountRecentChirps error:", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(struct {
+			Error string `json:"error"`
+		}{
+			Error: "Something went wrong",
+		})
+		return false
+	}
+	err = entitlements.For(user.IsChirpyRed).CheckChirpRate(int(recent.Count))
+	if err == nil {
+		return true
+	}
+	writeChirpRateLimited(w, err, recent.Oldest)
+	return false
+}
+
+// writeChirpRateLimited answers 429 for a posting-rate denial. oldest is
+// the oldest chirp counted against the limit.
+func writeChirpRateLimited(w http.ResponseWriter, err error, oldest time.Time) {
+	// A slot frees up once the oldest chirp in the window ages out of it.
+	retryAfter := max(lockout.RetryAfter(oldest.Add(entitlements.RateWindow), time.Now().UTC()), 1)
+	w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+	w.WriteHeader(http.StatusTooManyRequests)
+	json.NewEncoder(w).Encode(struct {
+		Error      string `json:"error"`
+		Code       string `json:"code"`
+		RetryAfter int    `json:"retry_after"`
+	}{
+		Error:      err.Error(),
+		Code:       "rate_limited",
+		RetryAfter: retryAfter,
+	})
+}
@@ -0,0 +1,239 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/A-X-Z-Y-T-E/Chirpy/internal/auth"
+	"github.com/A-X-Z-Y-T-E/Chirpy/internal/database"
+	"github.com/A-X-Z-Y-T-E/Chirpy/internal/pagination"
+	"github.com/google/uuid"
+)
+
+type FollowUser struct {
+	ID            string `json:"id"`
+	Is_Chirpy_Red bool   `json:"is_chirpy_red"`
+	FollowedAt    string `json:"followed_at"`
+}
+
+type followPage struct {
+	Users      []FollowUser `json:"users"`
+	NextCursor string       `json:"next_cursor,omitempty"`
+}
+
+func (cfg *apiConfig) Follow() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		user, _ := auth.UserFromContext(r.Context())
+		UserID := user.ID
+
+		FolloweeID, err := convert_to_uuid(r.PathValue("userID"))
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(struct {
+				Error string `json:"error"`
+			}{
+				Error: "Invalid user ID",
+			})
+			return
+		}
+		if FolloweeID == UserID {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(struct {
+				Error string `json:"error"`
+			}{
+				Error: "You cannot follow yourself",
+			})
+			return
+		}
+		if _, err := cfg.db.GetUserFromId(r.Context(), FolloweeID); err != nil {
+			w.WriteHeader(http.StatusNotFound)
+			json.NewEncoder(w).Encode(struct {
+				Error string `json:"error"`
+			}{
+				Error: "User not found",
+			})
+			return
+		}
+
+		err = cfg.db.FollowUser(r.Context(), database.FollowUserParams{
+			FollowerID: UserID,
+			FolloweeID: FolloweeID,
+		})
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(struct {
+				Error string `json:"error"`
+			}{
+				Error: "Something went wrong",
+			})
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
+
+func (cfg *apiConfig) Unfollow() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		user, _ := auth.UserFromContext(r.Context())
+		UserID := user.ID
+
+		FolloweeID, err := convert_to_uuid(r.PathValue("userID"))
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(struct {
+				Error string `json:"error"`
+			}{
+				Error: "Invalid user ID",
+			})
+			return
+		}
+
+		err = cfg.db.UnfollowUser(r.Context(), database.UnfollowUserParams{
+			FollowerID: UserID,
+			FolloweeID: FolloweeID,
+		})
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(struct {
+				Error string `json:"error"`
+			}{
+				Error: "Something went wrong",
+			})
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
+
+// ListFollows serves both /followers and /following; followers selects which
+// side of the relationship to list.
+func (cfg *apiConfig) ListFollows(followers bool) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		UserID, err := convert_to_uuid(r.PathValue("userID"))
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(struct {
+				Error string `json:"error"`
+			}{
+				Error: "Invalid user ID",
+			})
+			return
+		}
+		page, err := pagination.FromQuery(r.URL.Query())
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(struct {
+				Error string `json:"error"`
+			}{
+				Error: err.Error(),
+			})
+			return
+		}
+
+		var rows []database.ListFollowersRow
+		if followers {
+			rows, err = cfg.db.ListFollowers(r.Context(), database.ListFollowersParams{
+				UserID:         UserID,
+				AfterCreatedAt: page.AfterCreatedAt,
+				AfterID:        page.AfterID,
+				PageLimit:      page.Limit + 1,
+			})
+		} else {
+			var following []database.ListFollowingRow
+			following, err = cfg.db.ListFollowing(r.Context(), database.ListFollowingParams{
+				UserID:         UserID,
+				AfterCreatedAt: page.AfterCreatedAt,
+				AfterID:        page.AfterID,
+				PageLimit:      page.Limit + 1,
+			})
+			for _, row := range following {
+				rows = append(rows, database.ListFollowersRow(row))
+			}
+		}
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(struct {
+				Error string `json:"error"`
+			}{
+				Error: "Internal Server Error.",
+			})
+			return
+		}
+
+		out := followPage{Users: []FollowUser{}}
+		if len(rows) > int(page.Limit) {
+			rows = rows[:page.Limit]
+			last := rows[len(rows)-1]
+			out.NextCursor = pagination.EncodeCursor(last.FollowedAt, last.ID)
+		}
+		for _, row := range rows {
+			out.Users = append(out.Users, FollowUser{
+				ID:            row.ID.String(),
+				Is_Chirpy_Red: row.IsChirpyRed,
+				FollowedAt:    row.FollowedAt.String(),
+			})
+		}
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(out)
+	})
+}
+
+func (cfg *apiConfig) Timeline() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		user, _ := auth.UserFromContext(r.Context())
+		UserID := user.ID
+		page, err := pagination.FromQuery(r.URL.Query())
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(struct {
+				Error string `json:"error"`
+			}{
+				Error: err.Error(),
+			})
+			return
+		}
+
+		chirps, err := cfg.db.ListTimeline(r.Context(), database.ListTimelineParams{
+			UserID:         UserID,
+			AfterCreatedAt: page.AfterCreatedAt,
+			AfterID:        page.AfterID,
+			PageLimit:      page.Limit + 1,
+		})
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(struct {
+				Error string `json:"error"`
+			}{
+				Error: "Internal Server Error.",
+			})
+			return
+		}
+
+		out := chirpPage{}
+		if len(chirps) > int(page.Limit) {
+			chirps = chirps[:page.Limit]
+			last := chirps[len(chirps)-1]
+			out.NextCursor = pagination.EncodeCursor(last.CreatedAt, last.ID)
+		}
+		out.Chirps, err = cfg.chirpsJSON(r.Context(), uuid.NullUUID{UUID: UserID, Valid: true}, chirps)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(struct {
+				Error string `json:"error"`
+			}{
+				Error: "Internal Server Error.",
+			})
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(out)
+	})
+}
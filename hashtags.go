@@ -0,0 +1,137 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/A-X-Z-Y-T-E/Chirpy/internal/database"
+	"github.com/A-X-Z-Y-T-E/Chirpy/internal/pagination"
+	"github.com/A-X-Z-Y-T-E/Chirpy/internal/textparse"
+)
+
+const (
+	defaultTrendingWindow = 24 * time.Hour
+	maxTrendingWindow     = 30 * 24 * time.Hour
+)
+
+type TrendingTag struct {
+	Tag  string `json:"tag"`
+	Uses int64  `json:"uses"`
+}
+
+func (cfg *apiConfig) HashtagChirps() http.Handler {
+	return http.HandlerFunc(func(resW http.ResponseWriter, req *http.Request) {
+		resW.Header().Set("Content-Type", "application/json")
+
+		tag := textparse.NormalizeHashtag(req.PathValue("tag"))
+		if tag == "" {
+			resW.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(resW).Encode(struct {
+				Error string `json:"error"`
+			}{
+				Error: "Invalid hashtag",
+			})
+			return
+		}
+		page, err := pagination.FromQuery(req.URL.Query())
+		if err != nil {
+			resW.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(resW).Encode(struct {
+				Error string `json:"error"`
+			}{
+				Error: err.Error(),
+			})
+			return
+		}
+
+		chirps, err := cfg.db.ListChirpsByHashtag(req.Context(), database.ListChirpsByHashtagParams{
+			Tag:            tag,
+			AfterCreatedAt: page.AfterCreatedAt,
+			AfterID:        page.AfterID,
+			PageLimit:      page.Limit + 1,
+		})
+		if err != nil {
+			resW.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(resW).Encode(struct {
+				Error string `json:"error"`
+			}{
+				Error: "Internal Server Error.",
+			})
+			return
+		}
+
+		out := chirpPage{}
+		if len(chirps) > int(page.Limit) {
+			chirps = chirps[:page.Limit]
+			last := chirps[len(chirps)-1]
+			out.NextCursor = pagination.EncodeCursor(last.CreatedAt, last.ID)
+		}
+		out.Chirps, err = cfg.chirpsJSON(req.Context(), cfg.viewerID(req), chirps)
+		if err != nil {
+			resW.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(resW).Encode(struct {
+				Error string `json:"error"`
+			}{
+				Error: "Internal Server Error.",
+			})
+			return
+		}
+		resW.WriteHeader(http.StatusOK)
+		json.NewEncoder(resW).Encode(out)
+	})
+}
+
+// TrendingHashtags ranks tags by how many chirps used them within the
+// trailing window, e.g. ?window=6h. The window defaults to 24 hours.
+func (cfg *apiConfig) TrendingHashtags() http.Handler {
+	return http.HandlerFunc(func(resW http.ResponseWriter, req *http.Request) {
+		resW.Header().Set("Content-Type", "application/json")
+
+		window := defaultTrendingWindow
+		if w := req.URL.Query().Get("window"); w != "" {
+			d, err := time.ParseDuration(w)
+			if err != nil || d <= 0 || d > maxTrendingWindow {
+				resW.WriteHeader(http.StatusBadRequest)
+				json.NewEncoder(resW).Encode(struct {
+					Error string `json:"error"`
+				}{
+					Error: "Invalid window",
+				})
+				return
+			}
+			window = d
+		}
+		limit, err := pagination.ParseLimit(req.URL.Query().Get("limit"))
+		if err != nil {
+			resW.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(resW).Encode(struct {
+				Error string `json:"error"`
+			}{
+				Error: err.Error(),
+			})
+			return
+		}
+
+		rows, err := cfg.db.TrendingHashtags(req.Context(), database.TrendingHashtagsParams{
+			Since:     time.Now().UTC().Add(-window),
+			PageLimit: limit,
+		})
+		if err != nil {
+			resW.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(resW).Encode(struct {
+				Error string `json:"error"`
+			}{
+				Error: "Internal Server Error.",
+			})
+			return
+		}
+
+		tags := []TrendingTag{}
+		for _, row := range rows {
+			tags = append(tags, TrendingTag{Tag: row.Tag, Uses: row.Uses})
+		}
+		resW.WriteHeader(http.StatusOK)
+		json.NewEncoder(resW).Encode(tags)
+	})
+}
@@ -1,45 +1,207 @@
 package auth
 
 import (
+	"errors"
+	"slices"
+	"strings"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
 )
 
-func MakeJWT(userID uuid.UUID, tokenSecret string) (string, error) {
+const (
+	Issuer          = "chirpy"
+	DefaultTokenTTL = 1 * time.Hour
+	// AudienceMFA marks the short-lived token a login hands out while it
+	// waits for the second factor. It is not an access token.
+	AudienceMFA = "chirpy-mfa"
+)
+
+var ErrMissingScope = errors.New("token is missing a required scope")
+
+// Claims is the JWT payload. Scopes travel as a single space-separated
+// "scope" claim, the same shape OAuth 2 uses.
+type Claims struct {
+	jwt.RegisteredClaims
+	Scope string `json:"scope,omitempty"`
+	Role  Role   `json:"role,omitempty"`
+}
+
+// Token is what a validated JWT says about its bearer.
+type Token struct {
+	UserID    uuid.UUID
+	Audience  []string
+	Scopes    []string
+	Role      Role
+	IssuedAt  time.Time
+	ExpiresAt time.Time
+}
+
+func (t *Token) HasScope(scope string) bool {
+	return slices.Contains(t.Scopes, scope)
+}
 
-	claims := &jwt.RegisteredClaims{
-		Issuer:    "chirpy",
-		IssuedAt:  jwt.NewNumericDate(time.Now().UTC()),
-		ExpiresAt: jwt.NewNumericDate(time.Now().UTC().Add(1 * time.Hour)),
-		Subject:   userID.String(),
+func (t *Token) HasAudience(aud string) bool {
+	return slices.Contains(t.Audience, aud)
+}
+
+type tokenOptions struct {
+	ttl      time.Duration
+	audience []string
+	scopes   []string
+	role     Role
+}
+
+// TokenOption customises a token issued by MakeJWT.
+type TokenOption func(*tokenOptions)
+
+func WithTTL(ttl time.Duration) TokenOption {
+	return func(o *tokenOptions) { o.ttl = ttl }
+}
+
+func WithAudience(aud ...string) TokenOption {
+	return func(o *tokenOptions) { o.audience = append(o.audience, aud...) }
+}
+
+func WithScopes(scopes ...string) TokenOption {
+	return func(o *tokenOptions) { o.scopes = append(o.scopes, scopes...) }
+}
+
+// WithRole records the user's role in the token. Tokens without one are
+// treated as RoleUser.
+func WithRole(role Role) TokenOption {
+	return func(o *tokenOptions) { o.role = role }
+}
+
+type validateOptions struct {
+	audience string
+	scopes   []string
+}
+
+// ValidateOption adds a check to ParseJWT on top of the signature and expiry.
+type ValidateOption func(*validateOptions)
+
+// RequireAudience rejects tokens that were not issued for aud.
+func RequireAudience(aud string) ValidateOption {
+	return func(o *validateOptions) { o.audience = aud }
+}
+
+// RequireScopes rejects tokens that lack any of scopes.
+func RequireScopes(scopes ...string) ValidateOption {
+	return func(o *validateOptions) { o.scopes = append(o.scopes, scopes...) }
+}
+
+// MakeJWT signs an access token with a bare HS256 secret. Deployments with
+// asymmetric keys use Keyring.MakeJWT instead.
+func MakeJWT(userID uuid.UUID, tokenSecret string, opts ...TokenOption) (string, error) {
+	keys, err := NewKeyring(NewHMACKey("", tokenSecret))
+	if err != nil {
+		return "", err
 	}
+	return keys.MakeJWT(userID, opts...)
+}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	tokenString, err := token.SignedString([]byte(tokenSecret))
+func ValidateJWT(tokenString, tokenSecret string) (uuid.UUID, error) {
+	token, err := ParseJWT(tokenString, tokenSecret)
+	if err != nil {
+		return uuid.UUID{}, err
+	}
+	return token.UserID, nil
+}
+
+func ParseJWT(tokenString, tokenSecret string, opts ...ValidateOption) (*Token, error) {
+	keys, err := NewKeyring(NewHMACKey("", tokenSecret))
+	if err != nil {
+		return nil, err
+	}
+	return keys.ParseJWT(tokenString, opts...)
+}
+
+func (k *Keyring) MakeJWT(userID uuid.UUID, opts ...TokenOption) (string, error) {
+	o := tokenOptions{ttl: DefaultTokenTTL}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	now := time.Now().UTC()
+	claims := &Claims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    Issuer,
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(o.ttl)),
+			Subject:   userID.String(),
+		},
+		Scope: strings.Join(o.scopes, " "),
+		Role:  o.role,
+	}
+	if len(o.audience) > 0 {
+		claims.Audience = o.audience
+	}
+
+	tokenString, err := k.sign(claims)
 	if err != nil {
 		return "", err
 	}
 	return tokenString, nil
 }
 
-func ValidateJWT(tokenString, tokenSecret string) (uuid.UUID, error) {
-	claims := &jwt.RegisteredClaims{}
-	token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (any, error) {
-		return []byte(tokenSecret), nil
-	}, jwt.WithLeeway(5*time.Second))
+func (k *Keyring) ValidateJWT(tokenString string) (uuid.UUID, error) {
+	token, err := k.ParseJWT(tokenString)
 	if err != nil {
 		return uuid.UUID{}, err
 	}
+	return token.UserID, nil
+}
+
+// ParseJWT verifies tokenString and returns its claims. Every token must come
+// from this issuer; audience and scopes are only checked when asked for.
+func (k *Keyring) ParseJWT(tokenString string, opts ...ValidateOption) (*Token, error) {
+	var o validateOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	parserOpts := []jwt.ParserOption{
+		jwt.WithLeeway(5 * time.Second),
+		jwt.WithIssuer(Issuer),
+		jwt.WithExpirationRequired(),
+	}
+	if o.audience != "" {
+		parserOpts = append(parserOpts, jwt.WithAudience(o.audience))
+	}
+
+	claims := &Claims{}
+	token, err := jwt.ParseWithClaims(tokenString, claims, k.keyfunc, parserOpts...)
+	if err != nil {
+		return nil, err
+	}
 	if !token.Valid {
-		return uuid.UUID{}, jwt.ErrSignatureInvalid
+		return nil, jwt.ErrSignatureInvalid
 	}
 	// Extract user ID from Subject claim
 	userID, err := uuid.Parse(claims.Subject)
 	if err != nil {
-		return uuid.UUID{}, err
+		return nil, err
 	}
 
-	return userID, nil
+	out := &Token{
+		UserID:    userID,
+		Audience:  claims.Audience,
+		Scopes:    strings.Fields(claims.Scope),
+		Role:      claims.Role,
+		ExpiresAt: claims.ExpiresAt.Time,
+	}
+	if out.Role == "" {
+		out.Role = RoleUser
+	}
+	if claims.IssuedAt != nil {
+		out.IssuedAt = claims.IssuedAt.Time
+	}
+	for _, scope := range o.scopes {
+		if !out.HasScope(scope) {
+			return nil, ErrMissingScope
+		}
+	}
+	return out, nil
 }
@@ -0,0 +1,192 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+var ErrUnknownKey = errors.New("unknown signing key")
+
+// SigningKey is one entry in a Keyring. Private is nil for keys that are only
+// kept around to verify tokens signed before a rotation.
+type SigningKey struct {
+	ID      string
+	Method  jwt.SigningMethod
+	Private crypto.PrivateKey
+	Public  crypto.PublicKey
+}
+
+// NewHMACKey wraps the legacy shared tokenSecret. HMAC keys never appear in
+// the JWKS since publishing them would publish the secret.
+func NewHMACKey(id, secret string) *SigningKey {
+	return &SigningKey{
+		ID:      id,
+		Method:  jwt.SigningMethodHS256,
+		Private: []byte(secret),
+		Public:  []byte(secret),
+	}
+}
+
+// ParseSigningKeyPEM reads a PKCS#8 private key or a PKIX public key. Ed25519
+// keys sign with EdDSA and RSA keys with RS256.
+func ParseSigningKeyPEM(id string, data []byte) (*SigningKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("key %s: no PEM block found", id)
+	}
+	switch block.Type {
+	case "PRIVATE KEY":
+		priv, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("key %s: %w", id, err)
+		}
+		switch priv := priv.(type) {
+		case ed25519.PrivateKey:
+			return &SigningKey{ID: id, Method: jwt.SigningMethodEdDSA, Private: priv, Public: priv.Public()}, nil
+		case *rsa.PrivateKey:
+			return &SigningKey{ID: id, Method: jwt.SigningMethodRS256, Private: priv, Public: &priv.PublicKey}, nil
+		}
+	case "PUBLIC KEY":
+		pub, err := x509.ParsePKIXPublicKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("key %s: %w", id, err)
+		}
+		switch pub := pub.(type) {
+		case ed25519.PublicKey:
+			return &SigningKey{ID: id, Method: jwt.SigningMethodEdDSA, Public: pub}, nil
+		case *rsa.PublicKey:
+			return &SigningKey{ID: id, Method: jwt.SigningMethodRS256, Public: pub}, nil
+		}
+	}
+	return nil, fmt.Errorf("key %s: unsupported key type %q", id, block.Type)
+}
+
+// Keyring signs new tokens with its current key and verifies tokens signed by
+// any key it holds, which is what lets keys rotate without logging everyone
+// out.
+type Keyring struct {
+	current *SigningKey
+	keys    map[string]*SigningKey
+}
+
+func NewKeyring(current *SigningKey, previous ...*SigningKey) (*Keyring, error) {
+	if current == nil || current.Private == nil {
+		return nil, errors.New("current signing key must have a private key")
+	}
+	k := &Keyring{current: current, keys: map[string]*SigningKey{}}
+	for _, key := range append([]*SigningKey{current}, previous...) {
+		if _, dup := k.keys[key.ID]; dup {
+			return nil, fmt.Errorf("duplicate key id %q", key.ID)
+		}
+		k.keys[key.ID] = key
+	}
+	return k, nil
+}
+
+// LoadKeyring reads every <kid>.pem file in dir. The key named currentID
+// signs new tokens; the rest only verify.
+func LoadKeyring(dir, currentID string) (*Keyring, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.pem"))
+	if err != nil {
+		return nil, err
+	}
+	var current *SigningKey
+	previous := []*SigningKey{}
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		key, err := ParseSigningKeyPEM(strings.TrimSuffix(filepath.Base(path), ".pem"), data)
+		if err != nil {
+			return nil, err
+		}
+		if key.ID == currentID {
+			current = key
+		} else {
+			previous = append(previous, key)
+		}
+	}
+	if current == nil {
+		return nil, fmt.Errorf("current key %q not found in %s", currentID, dir)
+	}
+	return NewKeyring(current, previous...)
+}
+
+// Add registers an extra verification-only key, such as the legacy HMAC
+// secret while clients still hold tokens signed with it.
+func (k *Keyring) Add(key *SigningKey) error {
+	if _, dup := k.keys[key.ID]; dup {
+		return fmt.Errorf("duplicate key id %q", key.ID)
+	}
+	k.keys[key.ID] = key
+	return nil
+}
+
+func (k *Keyring) sign(claims jwt.Claims) (string, error) {
+	token := jwt.NewWithClaims(k.current.Method, claims)
+	if k.current.ID != "" {
+		token.Header["kid"] = k.current.ID
+	}
+	return token.SignedString(k.current.Private)
+}
+
+// keyfunc picks the verification key by kid and refuses tokens whose alg
+// does not match that key, so an RSA public key can never be used as an
+// HMAC secret.
+func (k *Keyring) keyfunc(token *jwt.Token) (any, error) {
+	kid, _ := token.Header["kid"].(string)
+	key, ok := k.keys[kid]
+	if !ok {
+		return nil, ErrUnknownKey
+	}
+	if token.Method.Alg() != key.Method.Alg() {
+		return nil, jwt.ErrTokenSignatureInvalid
+	}
+	return key.Public, nil
+}
+
+// JWK is a public key in RFC 7517 form.
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+}
+
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// JWKS lists the public half of every asymmetric key in the ring.
+func (k *Keyring) JWKS() JWKS {
+	set := JWKS{Keys: []JWK{}}
+	for _, key := range k.keys {
+		enc := base64.RawURLEncoding
+		switch pub := key.Public.(type) {
+		case ed25519.PublicKey:
+			set.Keys = append(set.Keys, JWK{Kty: "OKP", Kid: key.ID, Use: "sig", Alg: key.Method.Alg(), Crv: "Ed25519", X: enc.EncodeToString(pub)})
+		case *rsa.PublicKey:
+			set.Keys = append(set.Keys, JWK{Kty: "RSA", Kid: key.ID, Use: "sig", Alg: key.Method.Alg(), N: enc.EncodeToString(pub.N.Bytes()), E: enc.EncodeToString(big.NewInt(int64(pub.E)).Bytes())})
+		}
+	}
+	sort.Slice(set.Keys, func(i, j int) bool { return set.Keys[i].Kid < set.Keys[j].Kid })
+	return set
+}
@@ -0,0 +1,137 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/A-X-Z-Y-T-E/Chirpy/internal/database"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+type contextKey int
+
+const (
+	userKey contextKey = iota
+	tokenKey
+)
+
+var errNoToken = errors.New("no bearer token")
+
+// UserStore loads the account an access token was issued to.
+// *database.Queries satisfies it.
+type UserStore interface {
+	GetUserFromId(ctx context.Context, id uuid.UUID) (database.User, error)
+}
+
+// Authenticator turns the bearer token on a request into the user it belongs
+// to. Handlers behind its middleware read the user with UserFromContext
+// instead of parsing the token themselves.
+type Authenticator struct {
+	keys  *Keyring
+	users UserStore
+}
+
+func NewAuthenticator(keys *Keyring, users UserStore) *Authenticator {
+	return &Authenticator{keys: keys, users: users}
+}
+
+func UserFromContext(ctx context.Context) (database.User, bool) {
+	user, ok := ctx.Value(userKey).(database.User)
+	return user, ok
+}
+
+func TokenFromContext(ctx context.Context) (*Token, bool) {
+	token, ok := ctx.Value(tokenKey).(*Token)
+	return token, ok
+}
+
+func (a *Authenticator) authenticate(r *http.Request) (context.Context, error) {
+	if r.Header.Get("Authorization") == "" {
+		return nil, errNoToken
+	}
+	accessToken, err := GetBearerToken(r.Header)
+	if err != nil {
+		return nil, err
+	}
+	token, err := a.keys.ParseJWT(accessToken)
+	if err != nil {
+		return nil, err
+	}
+	// Access tokens carry no audience; one that does was issued for
+	// something else, such as an MFA challenge.
+	if len(token.Audience) > 0 {
+		return nil, jwt.ErrTokenInvalidAudience
+	}
+	// Loading the user also rejects tokens of accounts deleted since the
+	// token was issued.
+	user, err := a.users.GetUserFromId(r.Context(), token.UserID)
+	if err != nil {
+		return nil, err
+	}
+	ctx := context.WithValue(r.Context(), userKey, user)
+	return context.WithValue(ctx, tokenKey, token), nil
+}
+
+func writeAuthError(w http.ResponseWriter, status int, msg string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(struct {
+		Error string `json:"error"`
+	}{
+		Error: msg,
+	})
+}
+
+// unauthorized is the one 401 every protected endpoint sends. The challenge
+// follows RFC 6750: a request without credentials gets a bare challenge, a
+// bad token gets error="invalid_token".
+func unauthorized(w http.ResponseWriter, err error) {
+	challenge := `Bearer realm="chirpy"`
+	if !errors.Is(err, errNoToken) {
+		challenge += `, error="invalid_token"`
+	}
+	w.Header().Set("WWW-Authenticate", challenge)
+	writeAuthError(w, http.StatusUnauthorized, "Unauthorized")
+}
+
+// RequireAuth rejects requests without a valid access token.
+func (a *Authenticator) RequireAuth(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx, err := a.authenticate(r)
+		if err != nil {
+			unauthorized(w, err)
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// OptionalAuth is for endpoints that anyone may call but that show more to a
+// signed-in user. A missing or invalid token means an anonymous request.
+func (a *Authenticator) OptionalAuth(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx, err := a.authenticate(r)
+		if err != nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// RequireRole is RequireAuth plus a check that the user holds at least role.
+// The role is read from the database rather than the token, so a demotion
+// applies immediately.
+func (a *Authenticator) RequireRole(role Role, next http.Handler) http.Handler {
+	return a.RequireAuth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, _ := UserFromContext(r.Context())
+		if !Role(user.Role).AtLeast(role) {
+			writeAuthError(w, http.StatusForbidden, "Forbidden")
+			return
+		}
+		next.ServeHTTP(w, r)
+	}))
+}
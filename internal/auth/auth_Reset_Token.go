@@ -0,0 +1,23 @@
+package auth
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+)
+
+// MakeSingleUseToken returns a token to email to the user, for password
+// resets and email verification, and the hash to store in its place.
+func MakeSingleUseToken() (string, string, error) {
+	token, err := MakeRefreshToken()
+	if err != nil {
+		return "", "", err
+	}
+	return token, HashToken(token), nil
+}
+
+// HashToken is for random, high-entropy tokens only. A plain SHA-256 is
+// enough there; passwords still go through HashPassword.
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,30 @@
+package auth
+
+// Role is a user's privilege level. Each role includes everything the roles
+// below it may do.
+type Role string
+
+const (
+	RoleUser      Role = "user"
+	RoleModerator Role = "moderator"
+	RoleAdmin     Role = "admin"
+)
+
+var roleRank = map[Role]int{
+	RoleUser:      1,
+	RoleModerator: 2,
+	RoleAdmin:     3,
+}
+
+func ParseRole(s string) (Role, bool) {
+	role := Role(s)
+	_, ok := roleRank[role]
+	return role, ok
+}
+
+// AtLeast reports whether r grants the privileges of min. Unknown roles grant
+// nothing.
+func (r Role) AtLeast(min Role) bool {
+	rank, ok := roleRank[r]
+	return ok && rank >= roleRank[min]
+}
@@ -1,8 +1,24 @@
 package auth
 
 import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"database/sql"
+	"encoding/pem"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/A-X-Z-Y-T-E/Chirpy/internal/database"
+	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
 )
 
@@ -64,7 +80,7 @@ func TestJWT(t *testing.T) {
 	}
 
 	// Test with expired token
-	expiredToken, err := MakeJWT(userID, tokenSecret)
+	expiredToken, err := MakeJWT(userID, tokenSecret, WithTTL(-time.Minute))
 	if err != nil {
 		t.Fatalf("MakeJWT error for expired token: %v", err)
 	}
@@ -80,6 +96,69 @@ func TestJWT(t *testing.T) {
 	}
 }
 
+func TestJWTClaims(t *testing.T) {
+	tokenSecret := "my-super-secret-key"
+	userID := uuid.New()
+
+	token, err := MakeJWT(userID, tokenSecret,
+		WithAudience("chirpy-api"),
+		WithScopes("chirps:write", "users:read"),
+		WithTTL(10*time.Minute),
+	)
+	if err != nil {
+		t.Fatalf("MakeJWT error: %v", err)
+	}
+
+	parsed, err := ParseJWT(token, tokenSecret, RequireAudience("chirpy-api"), RequireScopes("chirps:write"))
+	if err != nil {
+		t.Fatalf("ParseJWT error: %v", err)
+	}
+	if parsed.UserID != userID {
+		t.Fatalf("expected user ID %v, got %v", userID, parsed.UserID)
+	}
+	if !parsed.HasScope("users:read") || parsed.HasScope("admin") {
+		t.Fatalf("unexpected scopes: %v", parsed.Scopes)
+	}
+	if !parsed.HasAudience("chirpy-api") {
+		t.Fatalf("unexpected audience: %v", parsed.Audience)
+	}
+	if ttl := parsed.ExpiresAt.Sub(parsed.IssuedAt); ttl != 10*time.Minute {
+		t.Fatalf("expected 10m TTL, got %v", ttl)
+	}
+
+	// Test wrong audience
+	_, err = ParseJWT(token, tokenSecret, RequireAudience("other-service"))
+	if err == nil {
+		t.Fatalf("expected error with wrong audience")
+	}
+
+	// Test missing scope
+	_, err = ParseJWT(token, tokenSecret, RequireScopes("admin"))
+	if !errors.Is(err, ErrMissingScope) {
+		t.Fatalf("expected ErrMissingScope, got %v", err)
+	}
+
+	// Test default token has no audience or scopes
+	plain, err := MakeJWT(userID, tokenSecret)
+	if err != nil {
+		t.Fatalf("MakeJWT error: %v", err)
+	}
+	parsed, err = ParseJWT(plain, tokenSecret)
+	if err != nil {
+		t.Fatalf("ParseJWT error: %v", err)
+	}
+	if len(parsed.Audience) != 0 || len(parsed.Scopes) != 0 {
+		t.Fatalf("expected no audience or scopes, got %v %v", parsed.Audience, parsed.Scopes)
+	}
+	if ttl := parsed.ExpiresAt.Sub(parsed.IssuedAt); ttl != DefaultTokenTTL {
+		t.Fatalf("expected default TTL, got %v", ttl)
+	}
+	_, err = ParseJWT(plain, tokenSecret, RequireAudience("chirpy-api"))
+	if err == nil {
+		t.Fatalf("expected error when audience is required but absent")
+	}
+}
+
 func TestBearerToken(t *testing.T) {
 	// Test valid bearer token
 	headers := make(map[string][]string)
@@ -135,3 +214,456 @@ func TestBearerToken(t *testing.T) {
 		t.Fatalf("expected error with too many parts")
 	}
 }
+
+func newEd25519Key(t *testing.T, id string) *SigningKey {
+	t.Helper()
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey error: %v", err)
+	}
+	return &SigningKey{ID: id, Method: jwt.SigningMethodEdDSA, Private: priv, Public: priv.Public()}
+}
+
+func TestKeyringSignAndValidate(t *testing.T) {
+	rsaPriv, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("rsa.GenerateKey error: %v", err)
+	}
+	keys := []*SigningKey{
+		newEd25519Key(t, "ed-1"),
+		{ID: "rsa-1", Method: jwt.SigningMethodRS256, Private: rsaPriv, Public: &rsaPriv.PublicKey},
+		NewHMACKey("", "legacy-secret"),
+	}
+	for _, key := range keys {
+		ring, err := NewKeyring(key)
+		if err != nil {
+			t.Fatalf("NewKeyring(%q) error: %v", key.ID, err)
+		}
+		userID := uuid.New()
+		token, err := ring.MakeJWT(userID)
+		if err != nil {
+			t.Fatalf("MakeJWT(%q) error: %v", key.ID, err)
+		}
+		got, err := ring.ValidateJWT(token)
+		if err != nil {
+			t.Fatalf("ValidateJWT(%q) error: %v", key.ID, err)
+		}
+		if got != userID {
+			t.Fatalf("expected user ID %v, got %v", userID, got)
+		}
+	}
+}
+
+func TestKeyringRotation(t *testing.T) {
+	oldKey := newEd25519Key(t, "2024-01")
+	newKey := newEd25519Key(t, "2024-06")
+
+	before, err := NewKeyring(oldKey)
+	if err != nil {
+		t.Fatalf("NewKeyring error: %v", err)
+	}
+	oldToken, err := before.MakeJWT(uuid.New())
+	if err != nil {
+		t.Fatalf("MakeJWT error: %v", err)
+	}
+
+	after, err := NewKeyring(newKey, oldKey)
+	if err != nil {
+		t.Fatalf("NewKeyring error: %v", err)
+	}
+	if _, err := after.ValidateJWT(oldToken); err != nil {
+		t.Fatalf("expected token signed with previous key to validate: %v", err)
+	}
+
+	// Once the old key is dropped its tokens are rejected.
+	dropped, err := NewKeyring(newKey)
+	if err != nil {
+		t.Fatalf("NewKeyring error: %v", err)
+	}
+	if _, err := dropped.ValidateJWT(oldToken); !errors.Is(err, ErrUnknownKey) {
+		t.Fatalf("expected ErrUnknownKey, got %v", err)
+	}
+
+	if _, err := NewKeyring(newKey, newKey); err == nil {
+		t.Fatalf("expected error with duplicate key id")
+	}
+	if _, err := NewKeyring(&SigningKey{ID: "pub", Method: jwt.SigningMethodEdDSA, Public: newKey.Public}); err == nil {
+		t.Fatalf("expected error with public-only current key")
+	}
+}
+
+func TestKeyringRejectsAlgConfusion(t *testing.T) {
+	rsaPriv, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("rsa.GenerateKey error: %v", err)
+	}
+	ring, err := NewKeyring(&SigningKey{ID: "rsa-1", Method: jwt.SigningMethodRS256, Private: rsaPriv, Public: &rsaPriv.PublicKey})
+	if err != nil {
+		t.Fatalf("NewKeyring error: %v", err)
+	}
+
+	// An HS256 token keyed with the published RSA modulus must not validate.
+	forged := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.RegisteredClaims{Subject: uuid.New().String()})
+	forged.Header["kid"] = "rsa-1"
+	token, err := forged.SignedString(rsaPriv.PublicKey.N.Bytes())
+	if err != nil {
+		t.Fatalf("SignedString error: %v", err)
+	}
+	if _, err := ring.ValidateJWT(token); err == nil {
+		t.Fatalf("expected error with alg mismatch")
+	}
+}
+
+func TestKeyringJWKS(t *testing.T) {
+	ed := newEd25519Key(t, "ed-1")
+	ring, err := NewKeyring(ed)
+	if err != nil {
+		t.Fatalf("NewKeyring error: %v", err)
+	}
+	if err := ring.Add(NewHMACKey("", "legacy-secret")); err != nil {
+		t.Fatalf("Add error: %v", err)
+	}
+
+	set := ring.JWKS()
+	if len(set.Keys) != 1 {
+		t.Fatalf("expected 1 published key, got %d", len(set.Keys))
+	}
+	jwk := set.Keys[0]
+	if jwk.Kid != "ed-1" || jwk.Kty != "OKP" || jwk.Crv != "Ed25519" || jwk.Alg != "EdDSA" || jwk.X == "" {
+		t.Fatalf("unexpected JWK: %+v", jwk)
+	}
+}
+
+func TestLoadKeyring(t *testing.T) {
+	dir := t.TempDir()
+	for _, id := range []string{"current", "previous"} {
+		_, priv, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			t.Fatalf("GenerateKey error: %v", err)
+		}
+		der, err := x509.MarshalPKCS8PrivateKey(priv)
+		if err != nil {
+			t.Fatalf("MarshalPKCS8PrivateKey error: %v", err)
+		}
+		data := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+		if err := os.WriteFile(filepath.Join(dir, id+".pem"), data, 0o600); err != nil {
+			t.Fatalf("WriteFile error: %v", err)
+		}
+	}
+
+	ring, err := LoadKeyring(dir, "current")
+	if err != nil {
+		t.Fatalf("LoadKeyring error: %v", err)
+	}
+	if got := len(ring.JWKS().Keys); got != 2 {
+		t.Fatalf("expected 2 published keys, got %d", got)
+	}
+	token, err := ring.MakeJWT(uuid.New())
+	if err != nil {
+		t.Fatalf("MakeJWT error: %v", err)
+	}
+	parsed, _, err := jwt.NewParser().ParseUnverified(token, &jwt.RegisteredClaims{})
+	if err != nil {
+		t.Fatalf("ParseUnverified error: %v", err)
+	}
+	if parsed.Header["kid"] != "current" {
+		t.Fatalf("expected kid 'current', got %v", parsed.Header["kid"])
+	}
+
+	if _, err := LoadKeyring(dir, "missing"); err == nil {
+		t.Fatalf("expected error with unknown current key")
+	}
+	if _, err := ParseSigningKeyPEM("bad", []byte("not pem")); err == nil {
+		t.Fatalf("expected error with malformed PEM")
+	}
+}
+
+func TestRoles(t *testing.T) {
+	if !RoleAdmin.AtLeast(RoleModerator) || !RoleModerator.AtLeast(RoleUser) {
+		t.Fatalf("expected higher roles to include lower ones")
+	}
+	if RoleUser.AtLeast(RoleModerator) || RoleModerator.AtLeast(RoleAdmin) {
+		t.Fatalf("expected lower roles not to include higher ones")
+	}
+	if Role("root").AtLeast(RoleUser) {
+		t.Fatalf("expected unknown role to grant nothing")
+	}
+	if _, ok := ParseRole("superuser"); ok {
+		t.Fatalf("expected unknown role to fail parsing")
+	}
+
+	tokenSecret := "my-super-secret-key"
+	token, err := MakeJWT(uuid.New(), tokenSecret, WithRole(RoleModerator))
+	if err != nil {
+		t.Fatalf("MakeJWT error: %v", err)
+	}
+	parsed, err := ParseJWT(token, tokenSecret)
+	if err != nil {
+		t.Fatalf("ParseJWT error: %v", err)
+	}
+	if parsed.Role != RoleModerator {
+		t.Fatalf("expected moderator role, got %q", parsed.Role)
+	}
+
+	// Tokens issued before roles existed count as plain users.
+	token, err = MakeJWT(uuid.New(), tokenSecret)
+	if err != nil {
+		t.Fatalf("MakeJWT error: %v", err)
+	}
+	parsed, err = ParseJWT(token, tokenSecret)
+	if err != nil {
+		t.Fatalf("ParseJWT error: %v", err)
+	}
+	if parsed.Role != RoleUser {
+		t.Fatalf("expected user role, got %q", parsed.Role)
+	}
+}
+
+type fakeUserStore map[uuid.UUID]database.User
+
+func (f fakeUserStore) GetUserFromId(ctx context.Context, id uuid.UUID) (database.User, error) {
+	user, ok := f[id]
+	if !ok {
+		return database.User{}, sql.ErrNoRows
+	}
+	return user, nil
+}
+
+func newTestAuthenticator(t *testing.T) (*Authenticator, *Keyring, fakeUserStore) {
+	t.Helper()
+	keys, err := NewKeyring(NewHMACKey("", "my-super-secret-key"))
+	if err != nil {
+		t.Fatalf("NewKeyring error: %v", err)
+	}
+	users := fakeUserStore{}
+	return NewAuthenticator(keys, users), keys, users
+}
+
+func bearerFor(t *testing.T, keys *Keyring, userID uuid.UUID) string {
+	t.Helper()
+	token, err := keys.MakeJWT(userID)
+	if err != nil {
+		t.Fatalf("MakeJWT error: %v", err)
+	}
+	return "Bearer " + token
+}
+
+func TestRequireAuth(t *testing.T) {
+	authn, keys, users := newTestAuthenticator(t)
+	user := database.User{ID: uuid.New(), Email: "a@example.com", Role: "user"}
+	users[user.ID] = user
+
+	var seen database.User
+	handler := authn.RequireAuth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen, _ = UserFromContext(r.Context())
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	cases := []struct {
+		name      string
+		header    string
+		want      int
+		challenge string
+	}{
+		{"no token", "", http.StatusUnauthorized, `Bearer realm="chirpy"`},
+		{"bad token", "Bearer not.a.token", http.StatusUnauthorized, `Bearer realm="chirpy", error="invalid_token"`},
+		{"deleted user", bearerFor(t, keys, uuid.New()), http.StatusUnauthorized, `Bearer realm="chirpy", error="invalid_token"`},
+		{"valid", bearerFor(t, keys, user.ID), http.StatusOK, ""},
+	}
+	for _, tc := range cases {
+		req := httptest.NewRequest(http.MethodGet, "/api/timeline", nil)
+		if tc.header != "" {
+			req.Header.Set("Authorization", tc.header)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		if rec.Code != tc.want {
+			t.Fatalf("%s: expected status %d, got %d", tc.name, tc.want, rec.Code)
+		}
+		if got := rec.Header().Get("WWW-Authenticate"); got != tc.challenge {
+			t.Fatalf("%s: expected challenge %q, got %q", tc.name, tc.challenge, got)
+		}
+	}
+	if seen.ID != user.ID {
+		t.Fatalf("expected user %v in context, got %v", user.ID, seen.ID)
+	}
+}
+
+func TestOptionalAuth(t *testing.T) {
+	authn, keys, users := newTestAuthenticator(t)
+	user := database.User{ID: uuid.New(), Role: "user"}
+	users[user.ID] = user
+
+	var found bool
+	handler := authn.OptionalAuth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, found = UserFromContext(r.Context())
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	for _, tc := range []struct {
+		header string
+		want   bool
+	}{
+		{"", false},
+		{"Bearer not.a.token", false},
+		{bearerFor(t, keys, user.ID), true},
+	} {
+		req := httptest.NewRequest(http.MethodGet, "/api/chirps", nil)
+		if tc.header != "" {
+			req.Header.Set("Authorization", tc.header)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("expected status 200, got %d", rec.Code)
+		}
+		if found != tc.want {
+			t.Fatalf("header %q: expected user found=%v, got %v", tc.header, tc.want, found)
+		}
+	}
+}
+
+func TestRequireRole(t *testing.T) {
+	authn, keys, users := newTestAuthenticator(t)
+	handler := authn.RequireRole(RoleAdmin, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	tokenFor := func(role Role) string {
+		user := database.User{ID: uuid.New(), Role: string(role)}
+		users[user.ID] = user
+		return bearerFor(t, keys, user.ID)
+	}
+	cases := []struct {
+		name   string
+		header string
+		want   int
+	}{
+		{"no token", "", http.StatusUnauthorized},
+		{"bad token", "Bearer not.a.token", http.StatusUnauthorized},
+		{"user", tokenFor(RoleUser), http.StatusForbidden},
+		{"moderator", tokenFor(RoleModerator), http.StatusForbidden},
+		{"admin", tokenFor(RoleAdmin), http.StatusOK},
+	}
+	for _, tc := range cases {
+		req := httptest.NewRequest(http.MethodGet, "/admin/metrics", nil)
+		if tc.header != "" {
+			req.Header.Set("Authorization", tc.header)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		if rec.Code != tc.want {
+			t.Fatalf("%s: expected status %d, got %d", tc.name, tc.want, rec.Code)
+		}
+	}
+}
+
+func TestMakeSingleUseToken(t *testing.T) {
+	token, hash, err := MakeSingleUseToken()
+	if err != nil {
+		t.Fatalf("MakeSingleUseToken error: %v", err)
+	}
+	if token == "" || hash == token {
+		t.Fatalf("expected a token and a distinct hash")
+	}
+	if HashToken(token) != hash {
+		t.Fatalf("expected HashToken to reproduce the stored hash")
+	}
+	other, _, err := MakeSingleUseToken()
+	if err != nil {
+		t.Fatalf("MakeSingleUseToken error: %v", err)
+	}
+	if other == token {
+		t.Fatalf("expected tokens to differ")
+	}
+}
+
+func TestTOTP(t *testing.T) {
+	// RFC 6238 appendix B, SHA-1, truncated to 6 digits.
+	secret := totpEncoding.EncodeToString([]byte("12345678901234567890"))
+	vectors := []struct {
+		unix int64
+		code string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+	}
+	for _, v := range vectors {
+		code, err := TOTPCode(secret, time.Unix(v.unix, 0))
+		if err != nil {
+			t.Fatalf("TOTPCode error: %v", err)
+		}
+		if code != v.code {
+			t.Fatalf("at %d: expected %s, got %s", v.unix, v.code, code)
+		}
+	}
+
+	now := time.Unix(1234567890, 0)
+	step, ok := ValidateTOTP(secret, "005924", now)
+	if !ok || step != 1234567890/30 {
+		t.Fatalf("expected code to validate at step %d, got %d %v", 1234567890/30, step, ok)
+	}
+	// One step of clock drift either way is tolerated, two is not.
+	if _, ok := ValidateTOTP(secret, "005924", now.Add(30*time.Second)); !ok {
+		t.Fatalf("expected code from the previous step to validate")
+	}
+	if _, ok := ValidateTOTP(secret, "005924", now.Add(90*time.Second)); ok {
+		t.Fatalf("expected stale code to fail")
+	}
+	if _, ok := ValidateTOTP(secret, "000000", now); ok {
+		t.Fatalf("expected wrong code to fail")
+	}
+
+	generated, err := GenerateTOTPSecret()
+	if err != nil {
+		t.Fatalf("GenerateTOTPSecret error: %v", err)
+	}
+	uri := TOTPURI("Chirpy", "a@example.com", generated)
+	if !strings.HasPrefix(uri, "otpauth://totp/Chirpy:a@example.com?") || !strings.Contains(uri, "secret="+generated) {
+		t.Fatalf("unexpected otpauth URI %q", uri)
+	}
+}
+
+func TestRecoveryCodes(t *testing.T) {
+	codes, err := GenerateRecoveryCodes(10)
+	if err != nil {
+		t.Fatalf("GenerateRecoveryCodes error: %v", err)
+	}
+	seen := map[string]bool{}
+	for _, code := range codes {
+		if len(code) != 9 || code[4] != '-' {
+			t.Fatalf("unexpected recovery code format %q", code)
+		}
+		if seen[code] {
+			t.Fatalf("duplicate recovery code %q", code)
+		}
+		seen[code] = true
+		typed := strings.ToUpper(strings.ReplaceAll(code, "-", ""))
+		if got := NormalizeRecoveryCode(" " + typed + " "); got != code {
+			t.Fatalf("expected %q to normalize to %q, got %q", typed, code, got)
+		}
+	}
+}
+
+func TestRequireAuthRejectsMFAToken(t *testing.T) {
+	authn, keys, users := newTestAuthenticator(t)
+	user := database.User{ID: uuid.New(), Role: "user"}
+	users[user.ID] = user
+
+	token, err := keys.MakeJWT(user.ID, WithAudience(AudienceMFA), WithTTL(5*time.Minute))
+	if err != nil {
+		t.Fatalf("MakeJWT error: %v", err)
+	}
+	handler := authn.RequireAuth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	req := httptest.NewRequest(http.MethodGet, "/api/timeline", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusUnauthorized {
+		t.Fatalf("expected MFA challenge token to be refused, got %d", rec.Code)
+	}
+}
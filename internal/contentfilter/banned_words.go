@@ -0,0 +1,44 @@
+package contentfilter
+
+import (
+	"regexp"
+	"strings"
+)
+
+const mask = "****"
+
+// BannedWords replaces whole-word, case-insensitive matches with ****.
+type BannedWords struct {
+	re *regexp.Regexp
+}
+
+func NewBannedWords(words []string) *BannedWords {
+	quoted := []string{}
+	for _, word := range words {
+		if word = strings.TrimSpace(word); word != "" {
+			quoted = append(quoted, regexp.QuoteMeta(word))
+		}
+	}
+	if len(quoted) == 0 {
+		return &BannedWords{}
+	}
+	return &BannedWords{
+		re: regexp.MustCompile(`(?i)\b(?:` + strings.Join(quoted, "|") + `)\b`),
+	}
+}
+
+// LoadBannedWords reads one banned word per line.
+func LoadBannedWords(path string) (*BannedWords, error) {
+	words, err := readLines(path)
+	if err != nil {
+		return nil, err
+	}
+	return NewBannedWords(words), nil
+}
+
+func (b *BannedWords) Apply(body string) (string, error) {
+	if b.re == nil {
+		return body, nil
+	}
+	return b.re.ReplaceAllString(body, mask), nil
+}
@@ -0,0 +1,44 @@
+package contentfilter
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// BlockPatterns rejects any body matching one of its regular expressions.
+type BlockPatterns struct {
+	patterns []*regexp.Regexp
+}
+
+func NewBlockPatterns(patterns []string) (*BlockPatterns, error) {
+	b := &BlockPatterns{}
+	for _, p := range patterns {
+		re, err := regexp.Compile(p)
+		if err != nil {
+			return nil, fmt.Errorf("block pattern %q: %w", p, err)
+		}
+		b.patterns = append(b.patterns, re)
+	}
+	return b, nil
+}
+
+// LoadBlockPatterns reads one regular expression per line.
+func LoadBlockPatterns(path string) (*BlockPatterns, error) {
+	patterns, err := readLines(path)
+	if err != nil {
+		return nil, err
+	}
+	return NewBlockPatterns(patterns)
+}
+
+func (b *BlockPatterns) Apply(body string) (string, error) {
+	for _, re := range b.patterns {
+		if re.MatchString(body) {
+			return "", &Rejection{
+				Rule:    "blocked_pattern",
+				Message: "Chirp contains blocked content",
+			}
+		}
+	}
+	return body, nil
+}
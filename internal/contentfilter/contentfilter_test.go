@@ -0,0 +1,67 @@
+package contentfilter
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestBannedWords(t *testing.T) {
+	f := NewBannedWords([]string{"kerfuffle", "sharbert"})
+	got, err := f.Apply("What a Kerfuffle! sharberts are fine, sharbert is not")
+	if err != nil {
+		t.Fatalf("Apply error: %v", err)
+	}
+	want := "What a ****! sharberts are fine, **** is not"
+	if got != want {
+		t.Fatalf("expected %q, got %q", want, got)
+	}
+
+	// An empty list leaves the body alone
+	got, _ = NewBannedWords(nil).Apply("kerfuffle")
+	if got != "kerfuffle" {
+		t.Fatalf("expected body unchanged, got %q", got)
+	}
+}
+
+func TestBlockPatternsAndChain(t *testing.T) {
+	block, err := NewBlockPatterns([]string{`(?i)buy now`})
+	if err != nil {
+		t.Fatalf("NewBlockPatterns error: %v", err)
+	}
+	chain := Chain{NewBannedWords([]string{"fornax"}), block}
+
+	got, err := chain.Apply("fornax is here")
+	if err != nil || got != "**** is here" {
+		t.Fatalf("expected masked body, got %q (%v)", got, err)
+	}
+
+	_, err = chain.Apply("BUY NOW cheap")
+	var rejection *Rejection
+	if !errors.As(err, &rejection) {
+		t.Fatalf("expected Rejection, got %v", err)
+	}
+	if rejection.Rule != "blocked_pattern" {
+		t.Fatalf("unexpected rule %q", rejection.Rule)
+	}
+
+	if _, err := NewBlockPatterns([]string{"("}); err == nil {
+		t.Fatalf("expected error for invalid pattern")
+	}
+}
+
+func TestLoadBannedWords(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "banned.txt")
+	if err := os.WriteFile(path, []byte("# comment\nkerfuffle\n\n  sharbert  \n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	f, err := LoadBannedWords(path)
+	if err != nil {
+		t.Fatalf("LoadBannedWords error: %v", err)
+	}
+	got, _ := f.Apply("kerfuffle sharbert comment")
+	if got != "**** **** comment" {
+		t.Fatalf("unexpected result %q", got)
+	}
+}
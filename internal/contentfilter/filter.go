@@ -0,0 +1,62 @@
+package contentfilter
+
+import (
+	"bufio"
+	"os"
+	"strings"
+)
+
+// Filter inspects a chirp body before it is stored. It either returns the
+// (possibly rewritten) body or an error. A *Rejection error means the body
+// itself is unacceptable; any other error is an internal failure.
+type Filter interface {
+	Apply(body string) (string, error)
+}
+
+// Rejection is returned by a filter that refuses a chirp body.
+type Rejection struct {
+	Rule    string
+	Message string
+}
+
+func (r *Rejection) Error() string {
+	return r.Message
+}
+
+// Chain runs filters in order, feeding each one the output of the last and
+// stopping at the first error.
+type Chain []Filter
+
+func (c Chain) Apply(body string) (string, error) {
+	for _, f := range c {
+		var err error
+		body, err = f.Apply(body)
+		if err != nil {
+			return "", err
+		}
+	}
+	return body, nil
+}
+
+// readLines returns the non-empty lines of a list file, skipping # comments.
+func readLines(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	lines := []string{}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		lines = append(lines, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return lines, nil
+}
@@ -7,29 +7,105 @@ package database
 
 import (
 	"context"
+	"database/sql"
+	"time"
 
 	"github.com/google/uuid"
+	"github.com/lib/pq"
 )
 
+const countRecentChirps = `-- name: CountRecentChirps :one
+SELECT COUNT(*) AS count, COALESCE(MIN(created_at), NOW())::timestamp AS oldest
+FROM chirps
+WHERE user_id = $1 AND created_at > $2
+`
+
+type CountRecentChirpsParams struct {
+	UserID    uuid.UUID
+	CreatedAt time.Time
+}
+
+type CountRecentChirpsRow struct {
+	Count  int64
+	Oldest time.Time
+}
+
+func (q *Queries) CountRecentChirps(ctx context.Context, arg CountRecentChirpsParams) (CountRecentChirpsRow, error) {
+	row := q.db.QueryRowContext(ctx, countRecentChirps, arg.UserID, arg.CreatedAt)
+	var i CountRecentChirpsRow
+	err := row.Scan(
+		&i.Count,
+		&i.Oldest,
+	)
+	return i, err
+}
+
+const countReplies = `-- name: CountReplies :many
+SELECT in_reply_to::uuid AS chirp_id, COUNT(*) AS reply_count
+FROM chirps
+WHERE in_reply_to = ANY($1::uuid[])
+GROUP BY in_reply_to
+`
+
+type CountRepliesRow struct {
+	ChirpID    uuid.UUID
+	ReplyCount int64
+}
+
+func (q *Queries) CountReplies(ctx context.Context, ids []uuid.UUID) ([]CountRepliesRow, error) {
+	rows, err := q.db.QueryContext(ctx, countReplies, pq.Array(ids))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []CountRepliesRow
+	for rows.Next() {
+		var i CountRepliesRow
+		if err := rows.Scan(
+			&i.ChirpID,
+			&i.ReplyCount,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const createChirp = `-- name: CreateChirp :one
-INSERT INTO chirps (id, created_at, updated_at,body,user_id)
+INSERT INTO chirps (id, created_at, updated_at,body,user_id,in_reply_to,reply_path)
 VALUES (
     gen_random_uuid(),
     CURRENT_TIMESTAMP,
     CURRENT_TIMESTAMP,
     $1,
-    $2
+    $2,
+    $3,
+    $4
 )
-RETURNING id, created_at, updated_at, body, user_id
+RETURNING id, created_at, updated_at, body, user_id, in_reply_to, reply_path
 `
 
 type CreateChirpParams struct {
-	Body   string
-	UserID uuid.UUID
+	Body      string
+	UserID    uuid.UUID
+	InReplyTo uuid.NullUUID
+	ReplyPath []uuid.UUID
 }
 
 func (q *Queries) CreateChirp(ctx context.Context, arg CreateChirpParams) (Chirp, error) {
-	row := q.db.QueryRowContext(ctx, createChirp, arg.Body, arg.UserID)
+	row := q.db.QueryRowContext(ctx, createChirp,
+		arg.Body,
+		arg.UserID,
+		arg.InReplyTo,
+		pq.Array(arg.ReplyPath),
+	)
 	var i Chirp
 	err := row.Scan(
 		&i.ID,
@@ -37,6 +113,8 @@ func (q *Queries) CreateChirp(ctx context.Context, arg CreateChirpParams) (Chirp
 		&i.UpdatedAt,
 		&i.Body,
 		&i.UserID,
+		&i.InReplyTo,
+		pq.Array(&i.ReplyPath),
 	)
 	return i, err
 }
@@ -52,7 +130,7 @@ func (q *Queries) DeleteChirp(ctx context.Context, id uuid.UUID) error {
 }
 
 const getChirpByID = `-- name: GetChirpByID :one
-SELECT id, created_at, updated_at, body, user_id FROM chirps
+SELECT id, created_at, updated_at, body, user_id, in_reply_to, reply_path FROM chirps
 WHERE id = $1
 `
 
@@ -65,18 +143,26 @@ func (q *Queries) GetChirpByID(ctx context.Context, id uuid.UUID) (Chirp, error)
 		&i.UpdatedAt,
 		&i.Body,
 		&i.UserID,
+		&i.InReplyTo,
+		pq.Array(&i.ReplyPath),
 	)
 	return i, err
 }
 
-const getChirpByUserID = `-- name: GetChirpByUserID :many
-SELECT id, created_at, updated_at, body, user_id FROM chirps
-WHERE user_id = $1
-ORDER BY created_at
+const getChirpDescendants = `-- name: GetChirpDescendants :many
+SELECT id, created_at, updated_at, body, user_id, in_reply_to, reply_path FROM chirps
+WHERE reply_path @> ARRAY[$1::uuid]
+ORDER BY created_at ASC, id ASC
+LIMIT $2
 `
 
-func (q *Queries) GetChirpByUserID(ctx context.Context, userID uuid.UUID) ([]Chirp, error) {
-	rows, err := q.db.QueryContext(ctx, getChirpByUserID, userID)
+type GetChirpDescendantsParams struct {
+	ChirpID   uuid.UUID
+	PageLimit int32
+}
+
+func (q *Queries) GetChirpDescendants(ctx context.Context, arg GetChirpDescendantsParams) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, getChirpDescendants, arg.ChirpID, arg.PageLimit)
 	if err != nil {
 		return nil, err
 	}
@@ -90,6 +176,8 @@ func (q *Queries) GetChirpByUserID(ctx context.Context, userID uuid.UUID) ([]Chi
 			&i.UpdatedAt,
 			&i.Body,
 			&i.UserID,
+			&i.InReplyTo,
+			pq.Array(&i.ReplyPath),
 		); err != nil {
 			return nil, err
 		}
@@ -104,13 +192,13 @@ func (q *Queries) GetChirpByUserID(ctx context.Context, userID uuid.UUID) ([]Chi
 	return items, nil
 }
 
-const returnChirps = `-- name: ReturnChirps :many
-SELECT id, created_at, updated_at, body, user_id FROM chirps
-ORDER BY created_at
+const getChirpsByIDs = `-- name: GetChirpsByIDs :many
+SELECT id, created_at, updated_at, body, user_id, in_reply_to, reply_path FROM chirps
+WHERE id = ANY($1::uuid[])
 `
 
-func (q *Queries) ReturnChirps(ctx context.Context) ([]Chirp, error) {
-	rows, err := q.db.QueryContext(ctx, returnChirps)
+func (q *Queries) GetChirpsByIDs(ctx context.Context, ids []uuid.UUID) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, getChirpsByIDs, pq.Array(ids))
 	if err != nil {
 		return nil, err
 	}
@@ -124,6 +212,8 @@ func (q *Queries) ReturnChirps(ctx context.Context) ([]Chirp, error) {
 			&i.UpdatedAt,
 			&i.Body,
 			&i.UserID,
+			&i.InReplyTo,
+			pq.Array(&i.ReplyPath),
 		); err != nil {
 			return nil, err
 		}
@@ -137,3 +227,123 @@ func (q *Queries) ReturnChirps(ctx context.Context) ([]Chirp, error) {
 	}
 	return items, nil
 }
+
+const listChirpsAsc = `-- name: ListChirpsAsc :many
+SELECT id, created_at, updated_at, body, user_id, in_reply_to, reply_path FROM chirps
+WHERE ($1::uuid IS NULL OR user_id = $1::uuid)
+AND (
+    $2::timestamp IS NULL
+    OR (created_at, id) > ($2::timestamp, $3::uuid)
+)
+ORDER BY created_at ASC, id ASC
+LIMIT $4
+`
+
+type ListChirpsAscParams struct {
+	AuthorID       uuid.NullUUID
+	AfterCreatedAt sql.NullTime
+	AfterID        uuid.NullUUID
+	PageLimit      int32
+}
+
+func (q *Queries) ListChirpsAsc(ctx context.Context, arg ListChirpsAscParams) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, listChirpsAsc,
+		arg.AuthorID,
+		arg.AfterCreatedAt,
+		arg.AfterID,
+		arg.PageLimit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Chirp
+	for rows.Next() {
+		var i Chirp
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Body,
+			&i.UserID,
+			&i.InReplyTo,
+			pq.Array(&i.ReplyPath),
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listChirpsDesc = `-- name: ListChirpsDesc :many
+SELECT id, created_at, updated_at, body, user_id, in_reply_to, reply_path FROM chirps
+WHERE ($1::uuid IS NULL OR user_id = $1::uuid)
+AND (
+    $2::timestamp IS NULL
+    OR (created_at, id) < ($2::timestamp, $3::uuid)
+)
+ORDER BY created_at DESC, id DESC
+LIMIT $4
+`
+
+type ListChirpsDescParams struct {
+	AuthorID       uuid.NullUUID
+	AfterCreatedAt sql.NullTime
+	AfterID        uuid.NullUUID
+	PageLimit      int32
+}
+
+func (q *Queries) ListChirpsDesc(ctx context.Context, arg ListChirpsDescParams) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, listChirpsDesc,
+		arg.AuthorID,
+		arg.AfterCreatedAt,
+		arg.AfterID,
+		arg.PageLimit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Chirp
+	for rows.Next() {
+		var i Chirp
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Body,
+			&i.UserID,
+			&i.InReplyTo,
+			pq.Array(&i.ReplyPath),
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const lockChirp = `-- name: LockChirp :one
+SELECT id FROM chirps
+WHERE id = $1
+FOR UPDATE
+`
+
+func (q *Queries) LockChirp(ctx context.Context, id uuid.UUID) (uuid.UUID, error) {
+	row := q.db.QueryRowContext(ctx, lockChirp, id)
+	err := row.Scan(&id)
+	return id, err
+}
@@ -6,6 +6,7 @@ package database
 
 import (
 	"database/sql"
+	"encoding/json"
 	"time"
 
 	"github.com/google/uuid"
@@ -17,22 +18,181 @@ type Chirp struct {
 	UpdatedAt time.Time
 	Body      string
 	UserID    uuid.UUID
+	InReplyTo uuid.NullUUID
+	ReplyPath []uuid.UUID
 }
 
-type RefreshToken struct {
-	Token     string
+type ChirpLike struct {
+	ChirpID   uuid.UUID
+	UserID    uuid.UUID
 	CreatedAt time.Time
-	UpdatedAt time.Time
+}
+
+type ChirpHashtag struct {
+	ChirpID   uuid.UUID
+	HashtagID uuid.UUID
+	CreatedAt time.Time
+}
+
+type ChirpMedium struct {
+	ID          uuid.UUID
+	CreatedAt   time.Time
+	ChirpID     uuid.UUID
+	StorageKey  string
+	ContentType string
+	SizeBytes   int64
+}
+
+type ChirpRevision struct {
+	ID         uuid.UUID
+	ChirpID    uuid.UUID
+	Body       string
+	CreatedAt  time.Time
+	ReplacedAt time.Time
+}
+
+type EmailVerification struct {
+	TokenHash string
+	UserID    uuid.UUID
+	Email     string
+	CreatedAt time.Time
+	ExpiresAt time.Time
+	UsedAt    sql.NullTime
+}
+
+type Follow struct {
+	FollowerID uuid.UUID
+	FolloweeID uuid.UUID
+	CreatedAt  time.Time
+}
+
+type Hashtag struct {
+	ID        uuid.UUID
+	CreatedAt time.Time
+	Tag       string
+}
+
+type LockoutEvent struct {
+	ID          uuid.UUID
+	UserID      uuid.NullUUID
+	ThrottleKey string
+	IpAddress   string
+	Failures    int32
+	LockedUntil time.Time
+	CreatedAt   time.Time
+	UnlockedAt  sql.NullTime
+}
+
+type LoginThrottle struct {
+	ThrottleKey   string
+	Failures      int32
+	LastFailureAt time.Time
+	LockedUntil   sql.NullTime
+}
+
+type Mention struct {
+	ChirpID   uuid.UUID
 	UserID    uuid.UUID
+	CreatedAt time.Time
+}
+
+type PasswordReset struct {
+	TokenHash string
+	UserID    uuid.UUID
+	CreatedAt time.Time
 	ExpiresAt time.Time
-	RevokedAt sql.NullTime
+	UsedAt    sql.NullTime
+}
+
+type RecoveryCode struct {
+	ID        uuid.UUID
+	UserID    uuid.UUID
+	CodeHash  string
+	CreatedAt time.Time
+	UsedAt    sql.NullTime
+}
+
+type RefreshToken struct {
+	Token      string
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+	UserID     uuid.UUID
+	ExpiresAt  time.Time
+	RevokedAt  sql.NullTime
+	FamilyID   uuid.UUID
+	RotatedAt  sql.NullTime
+	UserAgent  string
+	IpAddress  string
+	LastUsedAt time.Time
+}
+
+type Subscription struct {
+	UserID            uuid.UUID
+	Plan              string
+	Status            string
+	CurrentPeriodEnd  sql.NullTime
+	CancelAtPeriodEnd bool
+	CreatedAt         time.Time
+	UpdatedAt         time.Time
+	LastEventAt       sql.NullTime
 }
 
 type User struct {
+	ID              uuid.UUID
+	CreatedAt       time.Time
+	UpdatedAt       time.Time
+	Email           string
+	HashedPassword  string
+	IsChirpyRed     bool
+	Handle          sql.NullString
+	Role            string
+	EmailVerifiedAt sql.NullTime
+	PendingEmail    sql.NullString
+}
+
+type UserTotp struct {
+	UserID      uuid.UUID
+	Secret      string
+	CreatedAt   time.Time
+	ConfirmedAt sql.NullTime
+	LastStep    int64
+}
+
+type WebhookDelivery struct {
 	ID             uuid.UUID
+	SubscriptionID uuid.UUID
+	EventID        uuid.UUID
+	EventType      string
+	Payload        string
+	Status         string
+	Attempts       int32
+	LastStatusCode sql.NullInt32
+	LastError      sql.NullString
 	CreatedAt      time.Time
-	UpdatedAt      time.Time
-	Email          string
-	HashedPassword string
-	IsChirpyRed    bool
+	NextAttemptAt  time.Time
+	DeliveredAt    sql.NullTime
+}
+
+type WebhookEvent struct {
+	ID            uuid.UUID
+	Source        string
+	EventID       sql.NullString
+	EventType     string
+	Headers       json.RawMessage
+	Body          string
+	Status        string
+	Attempts      int32
+	LastError     sql.NullString
+	ReceivedAt    time.Time
+	NextAttemptAt time.Time
+	ProcessedAt   sql.NullTime
+}
+
+type WebhookSubscription struct {
+	ID         uuid.UUID
+	Url        string
+	Secret     string
+	EventTypes []string
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
 }
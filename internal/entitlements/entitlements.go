@@ -0,0 +1,121 @@
+// Package entitlements decides what each plan lets a user do. Handlers ask
+// it rather than checking is_chirpy_red themselves, so the perks of Chirpy
+// Red are defined in one place.
+package entitlements
+
+import (
+	"fmt"
+	"time"
+)
+
+type Plan string
+
+const (
+	Free Plan = "free"
+	Red  Plan = "red"
+)
+
+// Feature names the limit a Denied error is about.
+type Feature string
+
+const (
+	FeatureChirpLength Feature = "chirp_length"
+	FeatureChirpRate   Feature = "chirp_rate"
+	FeatureEditWindow  Feature = "edit_window"
+	FeatureMedia       Feature = "media"
+)
+
+// RateWindow is the period ChirpsPerWindow is counted over.
+const RateWindow = time.Hour
+
+type Limits struct {
+	MaxChirpLength  int
+	ChirpsPerWindow int
+	// EditWindow is how long after posting a chirp can still be edited.
+	EditWindow time.Duration
+	// MaxMediaPerChirp is zero for plans without attachments.
+	MaxMediaPerChirp int
+}
+
+var plans = map[Plan]Limits{
+	Free: {
+		MaxChirpLength:   140,
+		ChirpsPerWindow:  30,
+		EditWindow:       15 * time.Minute,
+		MaxMediaPerChirp: 0,
+	},
+	Red: {
+		MaxChirpLength:   500,
+		ChirpsPerWindow:  300,
+		EditWindow:       24 * time.Hour,
+		MaxMediaPerChirp: 4,
+	},
+}
+
+// Entitlements are the limits that apply to one user.
+type Entitlements struct {
+	Plan Plan
+	Limits
+}
+
+func For(isChirpyRed bool) Entitlements {
+	if isChirpyRed {
+		return Entitlements{Plan: Red, Limits: plans[Red]}
+	}
+	return Entitlements{Plan: Free, Limits: plans[Free]}
+}
+
+// Denied is returned when a plan does not allow something. RedAllows
+// reports whether Chirpy Red would, that is whether upgrading would help.
+type Denied struct {
+	Feature   Feature
+	RedAllows bool
+	msg       string
+}
+
+func (d *Denied) Error() string {
+	return d.msg
+}
+
+func (e Entitlements) deny(feature Feature, redAllows bool, msg string) error {
+	return &Denied{Feature: feature, RedAllows: e.Plan != Red && redAllows, msg: msg}
+}
+
+// CheckChirpLength checks the length in bytes of a chirp body.
+func (e Entitlements) CheckChirpLength(n int) error {
+	if n <= e.MaxChirpLength {
+		return nil
+	}
+	return e.deny(FeatureChirpLength, n <= plans[Red].MaxChirpLength, "Chirp is too long")
+}
+
+// CheckChirpRate checks whether another chirp may be posted when recent
+// have been posted in the last RateWindow.
+func (e Entitlements) CheckChirpRate(recent int) error {
+	if recent < e.ChirpsPerWindow {
+		return nil
+	}
+	return e.deny(FeatureChirpRate, recent < plans[Red].ChirpsPerWindow, "Too many chirps, try again later")
+}
+
+// CheckEdit checks whether a chirp posted at createdAt may still be edited.
+func (e Entitlements) CheckEdit(createdAt, now time.Time) error {
+	age := now.Sub(createdAt)
+	if age <= e.EditWindow {
+		return nil
+	}
+	return e.deny(FeatureEditWindow, age <= plans[Red].EditWindow, "Chirp can no longer be edited")
+}
+
+// CheckMedia checks whether a chirp that already has count attachments may
+// get another.
+func (e Entitlements) CheckMedia(count int) error {
+	if e.MaxMediaPerChirp == 0 {
+		return e.deny(FeatureMedia, true, "Media attachments require Chirpy Red")
+	}
+	if count < e.MaxMediaPerChirp {
+		return nil
+	}
+	return e.deny(FeatureMedia, count < plans[Red].MaxMediaPerChirp,
+		fmt.Sprintf("A chirp can have at most %d attachments", e.MaxMediaPerChirp))
+}
@@ -0,0 +1,69 @@
+package entitlements
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestFor(t *testing.T) {
+	if got := For(false).Plan; got != Free {
+		t.Errorf("For(false).Plan = %q, want %q", got, Free)
+	}
+	if got := For(true).Plan; got != Red {
+		t.Errorf("For(true).Plan = %q, want %q", got, Red)
+	}
+	free, red := For(false), For(true)
+	if red.MaxChirpLength <= free.MaxChirpLength || red.ChirpsPerWindow <= free.ChirpsPerWindow ||
+		red.EditWindow <= free.EditWindow || red.MaxMediaPerChirp <= free.MaxMediaPerChirp {
+		t.Errorf("Red limits %+v should all exceed Free limits %+v", red.Limits, free.Limits)
+	}
+}
+
+func TestChecks(t *testing.T) {
+	free, red := For(false), For(true)
+	now := time.Now()
+
+	cases := []struct {
+		name      string
+		err       error
+		feature   Feature
+		redAllows bool
+	}{
+		{"free short chirp", free.CheckChirpLength(140), "", false},
+		{"free long chirp", free.CheckChirpLength(141), FeatureChirpLength, true},
+		{"free very long chirp", free.CheckChirpLength(501), FeatureChirpLength, false},
+		{"red long chirp", red.CheckChirpLength(500), "", false},
+		{"red too long", red.CheckChirpLength(501), FeatureChirpLength, false},
+
+		{"free under rate", free.CheckChirpRate(29), "", false},
+		{"free at rate", free.CheckChirpRate(30), FeatureChirpRate, true},
+		{"red under rate", red.CheckChirpRate(30), "", false},
+		{"red at rate", red.CheckChirpRate(300), FeatureChirpRate, false},
+
+		{"free fresh edit", free.CheckEdit(now.Add(-time.Minute), now), "", false},
+		{"free late edit", free.CheckEdit(now.Add(-time.Hour), now), FeatureEditWindow, true},
+		{"red late edit", red.CheckEdit(now.Add(-time.Hour), now), "", false},
+		{"red stale edit", red.CheckEdit(now.Add(-48*time.Hour), now), FeatureEditWindow, false},
+
+		{"free media", free.CheckMedia(0), FeatureMedia, true},
+		{"red media", red.CheckMedia(3), "", false},
+		{"red media full", red.CheckMedia(4), FeatureMedia, false},
+	}
+	for _, tc := range cases {
+		if tc.feature == "" {
+			if tc.err != nil {
+				t.Errorf("%s: unexpected error %v", tc.name, tc.err)
+			}
+			continue
+		}
+		var denied *Denied
+		if !errors.As(tc.err, &denied) {
+			t.Errorf("%s: expected Denied, got %v", tc.name, tc.err)
+			continue
+		}
+		if denied.Feature != tc.feature || denied.RedAllows != tc.redAllows {
+			t.Errorf("%s: got feature %q red allows %v, want %q %v", tc.name, denied.Feature, denied.RedAllows, tc.feature, tc.redAllows)
+		}
+	}
+}
@@ -0,0 +1,74 @@
+// Package jobs runs the server's periodic background work.
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// Trigger wakes a job started with Run ahead of its next tick.
+type Trigger chan struct{}
+
+func NewTrigger() Trigger {
+	return make(Trigger, 1)
+}
+
+// Fire never blocks. Fires that arrive while one is already pending are
+// merged into it, since a single run picks up all outstanding work.
+func (t Trigger) Fire() {
+	select {
+	case t <- struct{}{}:
+	default:
+	}
+}
+
+// Every calls fn once per interval until ctx is done. The first call happens
+// straight away so that work which piled up while the server was down is
+// not left waiting for a full interval. Errors are logged and the job keeps
+// running; a call that overruns the interval delays the next one rather
+// than overlapping it.
+func Every(ctx context.Context, name string, interval time.Duration, fn func(context.Context) error) {
+	Run(ctx, name, interval, nil, fn)
+}
+
+// Run is Every, except that fn is also called whenever trigger fires. A nil
+// trigger never fires.
+func Run(ctx context.Context, name string, interval time.Duration, trigger Trigger, fn func(context.Context) error) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if err := fn(ctx); err != nil {
+			fmt.Println(name, "error:", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-trigger:
+		}
+	}
+}
+
+// Backoff spaces out retries: Base after the first failed attempt, doubling
+// with each one after that, and never more than Max.
+type Backoff struct {
+	Base time.Duration
+	Max  time.Duration
+}
+
+// Delay is how long to wait after the given attempt, counting from 1, has
+// failed.
+func (b Backoff) Delay(attempt int) time.Duration {
+	if attempt < 1 {
+		attempt = 1
+	}
+	delay := b.Base
+	for i := 1; i < attempt; i++ {
+		delay *= 2
+		if delay >= b.Max {
+			return b.Max
+		}
+	}
+	return min(delay, b.Max)
+}
@@ -0,0 +1,102 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestEvery(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	var calls atomic.Int32
+	done := make(chan struct{})
+	go func() {
+		Every(ctx, "test", time.Millisecond, func(context.Context) error {
+			if calls.Add(1) == 3 {
+				cancel()
+			}
+			return errors.New("keeps going")
+		})
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Every did not stop after the context was canceled")
+	}
+	if got := calls.Load(); got != 3 {
+		t.Errorf("fn called %d times, want 3", got)
+	}
+}
+
+func TestEveryRunsImmediately(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	called := make(chan struct{}, 1)
+	go Every(ctx, "test", time.Hour, func(context.Context) error {
+		select {
+		case called <- struct{}{}:
+		default:
+		}
+		return nil
+	})
+
+	select {
+	case <-called:
+	case <-time.After(time.Second):
+		t.Fatal("first call waited for the interval")
+	}
+}
+
+func TestRunTrigger(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	trigger := NewTrigger()
+	calls := make(chan struct{}, 2)
+	go Run(ctx, "test", time.Hour, trigger, func(context.Context) error {
+		calls <- struct{}{}
+		return nil
+	})
+
+	<-calls
+	trigger.Fire()
+	select {
+	case <-calls:
+	case <-time.After(time.Second):
+		t.Fatal("Fire did not wake the job")
+	}
+}
+
+func TestTriggerFireDoesNotBlock(t *testing.T) {
+	trigger := NewTrigger()
+	for i := 0; i < 3; i++ {
+		trigger.Fire()
+	}
+	if len(trigger) != 1 {
+		t.Errorf("pending fires = %d, want 1", len(trigger))
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	b := Backoff{Base: 30 * time.Second, Max: 10 * time.Minute}
+	tests := []struct {
+		attempt int
+		want    time.Duration
+	}{
+		{0, 30 * time.Second},
+		{1, 30 * time.Second},
+		{2, time.Minute},
+		{3, 2 * time.Minute},
+		{5, 8 * time.Minute},
+		{6, 10 * time.Minute},
+		{100, 10 * time.Minute},
+	}
+	for _, tt := range tests {
+		if got := b.Delay(tt.attempt); got != tt.want {
+			t.Errorf("Delay(%d) = %v, want %v", tt.attempt, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,86 @@
+// Package lockout decides how long to refuse logins after repeated
+// failures, and other requests that are cheap to repeat after too many of
+// them. Counting is left to the caller's storage.
+package lockout
+
+import "time"
+
+// Policy locks a key once it reaches Threshold failures, for BaseDelay,
+// doubling with every further failure up to MaxDelay. Failures older than
+// Window are forgotten.
+type Policy struct {
+	Threshold int
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+	Window    time.Duration
+}
+
+// Email guards a single account. It is strict because guessing one user's
+// password is exactly what it stops.
+var Email = Policy{
+	Threshold: 5,
+	BaseDelay: 30 * time.Second,
+	MaxDelay:  1 * time.Hour,
+	Window:    24 * time.Hour,
+}
+
+// IP guards against one client spraying many accounts. Its threshold is
+// higher since many users can share an address behind NAT.
+var IP = Policy{
+	Threshold: 50,
+	BaseDelay: 1 * time.Minute,
+	MaxDelay:  1 * time.Hour,
+	Window:    1 * time.Hour,
+}
+
+// ResetEmail limits password reset mail to one address, so the form cannot
+// be used to flood someone's inbox.
+var ResetEmail = Policy{
+	Threshold: 3,
+	BaseDelay: 15 * time.Minute,
+	MaxDelay:  24 * time.Hour,
+	Window:    1 * time.Hour,
+}
+
+// ResetIP limits password reset requests from one client across addresses.
+var ResetIP = Policy{
+	Threshold: 20,
+	BaseDelay: 15 * time.Minute,
+	MaxDelay:  24 * time.Hour,
+	Window:    1 * time.Hour,
+}
+
+// VerificationMail allows one verification email per account a minute, so
+// an account cannot be used to flood whatever address it names.
+var VerificationMail = Policy{
+	Threshold: 1,
+	BaseDelay: 1 * time.Minute,
+	MaxDelay:  1 * time.Minute,
+	Window:    1 * time.Minute,
+}
+
+// LockFor returns how long to lock after the given number of consecutive
+// failures, or zero if it is still under the threshold.
+func (p Policy) LockFor(failures int) time.Duration {
+	if failures < p.Threshold {
+		return 0
+	}
+	delay := p.BaseDelay
+	for i := p.Threshold; i < failures; i++ {
+		delay *= 2
+		if delay >= p.MaxDelay {
+			return p.MaxDelay
+		}
+	}
+	return delay
+}
+
+// RetryAfter is the value for a Retry-After header: whole seconds until
+// lockedUntil, rounded up so a client never retries early.
+func RetryAfter(lockedUntil, now time.Time) int {
+	d := lockedUntil.Sub(now)
+	if d <= 0 {
+		return 0
+	}
+	return int((d + time.Second - 1) / time.Second)
+}
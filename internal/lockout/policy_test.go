@@ -0,0 +1,46 @@
+package lockout
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLockFor(t *testing.T) {
+	p := Policy{Threshold: 3, BaseDelay: time.Minute, MaxDelay: 10 * time.Minute}
+	cases := []struct {
+		failures int
+		want     time.Duration
+	}{
+		{0, 0},
+		{2, 0},
+		{3, time.Minute},
+		{4, 2 * time.Minute},
+		{5, 4 * time.Minute},
+		{6, 8 * time.Minute},
+		{7, 10 * time.Minute},
+		{100, 10 * time.Minute},
+	}
+	for _, tc := range cases {
+		if got := p.LockFor(tc.failures); got != tc.want {
+			t.Fatalf("LockFor(%d): expected %v, got %v", tc.failures, tc.want, got)
+		}
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	cases := []struct {
+		until time.Time
+		want  int
+	}{
+		{now.Add(-time.Second), 0},
+		{now, 0},
+		{now.Add(1500 * time.Millisecond), 2},
+		{now.Add(time.Minute), 60},
+	}
+	for _, tc := range cases {
+		if got := RetryAfter(tc.until, now); got != tc.want {
+			t.Fatalf("RetryAfter(%v): expected %d, got %d", tc.until.Sub(now), tc.want, got)
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package mailer
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFormat(t *testing.T) {
+	msg := Message{To: "a@example.com", Subject: "Reset your password", Body: "hello"}
+	out := string(format("chirpy@example.com", msg, time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)))
+
+	for _, want := range []string{
+		"From: chirpy@example.com\r\n",
+		"To: a@example.com\r\n",
+		"Subject: Reset your password\r\n",
+		"Date: Tue, 02 Jan 2024 03:04:05 +0000\r\n",
+		"Content-Type: text/plain; charset=utf-8\r\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("expected %q in message:\n%s", want, out)
+		}
+	}
+	if !strings.HasSuffix(out, "\r\n\r\nhello") {
+		t.Fatalf("expected body after blank line:\n%s", out)
+	}
+}
+
+func TestFileMailer(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "mail")
+	m, err := NewFileMailer(dir, "chirpy@example.com")
+	if err != nil {
+		t.Fatalf("NewFileMailer error: %v", err)
+	}
+	if err := m.Send(context.Background(), Message{To: "a@example.com", Subject: "Hi", Body: "token 123"}); err != nil {
+		t.Fatalf("Send error: %v", err)
+	}
+
+	files, err := filepath.Glob(filepath.Join(dir, "*.eml"))
+	if err != nil || len(files) != 1 {
+		t.Fatalf("expected 1 .eml file, got %v (%v)", files, err)
+	}
+	data, err := os.ReadFile(files[0])
+	if err != nil {
+		t.Fatalf("ReadFile error: %v", err)
+	}
+	if !strings.Contains(string(data), "token 123") {
+		t.Fatalf("unexpected file contents %q", data)
+	}
+}
+
+func TestMemoryMailer(t *testing.T) {
+	m := NewMemoryMailer()
+	ctx := context.Background()
+	m.Send(ctx, Message{To: "a@example.com"})
+	m.Send(ctx, Message{To: "b@example.com"})
+
+	sent := m.Sent()
+	if len(sent) != 2 || sent[0].To != "a@example.com" || sent[1].To != "b@example.com" {
+		t.Fatalf("unexpected sent messages %+v", sent)
+	}
+}
+
+func TestSMTPMailerRejectsHeaderInjection(t *testing.T) {
+	m := NewSMTPMailer("localhost:0", "chirpy@example.com", "", "")
+	err := m.Send(context.Background(), Message{To: "a@example.com\r\nBcc: b@example.com", Subject: "Hi"})
+	if !errors.Is(err, ErrInvalidAddress) {
+		t.Fatalf("expected ErrInvalidAddress, got %v", err)
+	}
+}
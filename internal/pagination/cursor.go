@@ -0,0 +1,137 @@
+package pagination
+
+import (
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	DefaultLimit = 50
+	MaxLimit     = 100
+)
+
+var (
+	ErrInvalidCursor = errors.New("invalid cursor")
+	ErrInvalidLimit  = errors.New("invalid limit")
+)
+
+// Cursor points at the last row of a page. The next page starts strictly
+// after (CreatedAt, ID) in whatever direction the listing is sorted.
+type Cursor struct {
+	CreatedAt time.Time
+	ID        uuid.UUID
+}
+
+func EncodeCursor(createdAt time.Time, id uuid.UUID) string {
+	raw := createdAt.UTC().Format(time.RFC3339Nano) + "|" + id.String()
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func DecodeCursor(cursor string) (Cursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return Cursor{}, ErrInvalidCursor
+	}
+	parts := strings.Split(string(raw), "|")
+	if len(parts) != 2 {
+		return Cursor{}, ErrInvalidCursor
+	}
+	createdAt, err := time.Parse(time.RFC3339Nano, parts[0])
+	if err != nil {
+		return Cursor{}, ErrInvalidCursor
+	}
+	id, err := uuid.Parse(parts[1])
+	if err != nil {
+		return Cursor{}, ErrInvalidCursor
+	}
+	return Cursor{CreatedAt: createdAt, ID: id}, nil
+}
+
+// ParseLimit reads a page size from a query string value, falling back to
+// DefaultLimit when empty and capping at MaxLimit.
+func ParseLimit(limit string) (int32, error) {
+	if limit == "" {
+		return DefaultLimit, nil
+	}
+	n, err := strconv.Atoi(limit)
+	if err != nil || n < 1 {
+		return 0, ErrInvalidLimit
+	}
+	if n > MaxLimit {
+		n = MaxLimit
+	}
+	return int32(n), nil
+}
+
+// Page holds the keyset parameters for a listing query. AfterCreatedAt and
+// AfterID are null on the first page.
+type Page struct {
+	Limit          int32
+	AfterCreatedAt sql.NullTime
+	AfterID        uuid.NullUUID
+}
+
+// FromQuery reads the limit and cursor query parameters.
+func FromQuery(query url.Values) (Page, error) {
+	limit, err := ParseLimit(query.Get("limit"))
+	if err != nil {
+		return Page{}, err
+	}
+	page := Page{Limit: limit}
+	if cursor := query.Get("cursor"); cursor != "" {
+		c, err := DecodeCursor(cursor)
+		if err != nil {
+			return Page{}, err
+		}
+		page.AfterCreatedAt = sql.NullTime{Time: c.CreatedAt, Valid: true}
+		page.AfterID = uuid.NullUUID{UUID: c.ID, Valid: true}
+	}
+	return page, nil
+}
+
+// Ranked listings such as search results have no stable keyset, so their
+// cursors carry a plain row offset instead.
+
+func EncodeOffsetCursor(offset int32) string {
+	raw := "offset|" + strconv.Itoa(int(offset))
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func DecodeOffsetCursor(cursor string) (int32, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return 0, ErrInvalidCursor
+	}
+	n, ok := strings.CutPrefix(string(raw), "offset|")
+	if !ok {
+		return 0, ErrInvalidCursor
+	}
+	// Parsing at 32 bits rejects offsets that would wrap when narrowed.
+	offset, err := strconv.ParseInt(n, 10, 32)
+	if err != nil || offset < 0 {
+		return 0, ErrInvalidCursor
+	}
+	return int32(offset), nil
+}
+
+// OffsetFromQuery reads the limit and an offset cursor from the query string.
+func OffsetFromQuery(query url.Values) (limit int32, offset int32, err error) {
+	limit, err = ParseLimit(query.Get("limit"))
+	if err != nil {
+		return 0, 0, err
+	}
+	if cursor := query.Get("cursor"); cursor != "" {
+		offset, err = DecodeOffsetCursor(cursor)
+		if err != nil {
+			return 0, 0, err
+		}
+	}
+	return limit, offset, nil
+}
@@ -0,0 +1,82 @@
+package pagination
+
+import (
+	"encoding/base64"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestCursorRoundTrip(t *testing.T) {
+	createdAt := time.Date(2025, 3, 14, 15, 9, 26, 535897000, time.UTC)
+	id := uuid.New()
+
+	cursor := EncodeCursor(createdAt, id)
+	if cursor == "" {
+		t.Fatalf("expected non-empty cursor")
+	}
+
+	decoded, err := DecodeCursor(cursor)
+	if err != nil {
+		t.Fatalf("DecodeCursor error: %v", err)
+	}
+	if !decoded.CreatedAt.Equal(createdAt) {
+		t.Fatalf("expected created_at %v, got %v", createdAt, decoded.CreatedAt)
+	}
+	if decoded.ID != id {
+		t.Fatalf("expected id %v, got %v", id, decoded.ID)
+	}
+
+	// Malformed cursors
+	for _, bad := range []string{"not base64!", "bm9waXBl", EncodeCursor(createdAt, id)[:10]} {
+		if _, err := DecodeCursor(bad); err == nil {
+			t.Fatalf("expected error decoding %q", bad)
+		}
+	}
+}
+
+func TestParseLimit(t *testing.T) {
+	n, err := ParseLimit("")
+	if err != nil || n != DefaultLimit {
+		t.Fatalf("expected default limit, got %d (%v)", n, err)
+	}
+	n, err = ParseLimit("10")
+	if err != nil || n != 10 {
+		t.Fatalf("expected 10, got %d (%v)", n, err)
+	}
+	n, err = ParseLimit("100000")
+	if err != nil || n != MaxLimit {
+		t.Fatalf("expected limit capped at %d, got %d (%v)", MaxLimit, n, err)
+	}
+	for _, bad := range []string{"0", "-3", "ten"} {
+		if _, err := ParseLimit(bad); err == nil {
+			t.Fatalf("expected error for limit %q", bad)
+		}
+	}
+}
+
+func TestOffsetCursor(t *testing.T) {
+	offset, err := DecodeOffsetCursor(EncodeOffsetCursor(150))
+	if err != nil {
+		t.Fatalf("DecodeOffsetCursor error: %v", err)
+	}
+	if offset != 150 {
+		t.Fatalf("expected offset 150, got %d", offset)
+	}
+
+	// A keyset cursor is not an offset cursor
+	if _, err := DecodeOffsetCursor(EncodeCursor(time.Now(), uuid.New())); err == nil {
+		t.Fatalf("expected error decoding keyset cursor as offset")
+	}
+
+	// Offsets past int32 must not wrap around to a negative number
+	crafted := base64.RawURLEncoding.EncodeToString([]byte("offset|3000000000"))
+	if _, err := DecodeOffsetCursor(crafted); err != ErrInvalidCursor {
+		t.Fatalf("expected ErrInvalidCursor for overflowing offset, got %v", err)
+	}
+	negative := base64.RawURLEncoding.EncodeToString([]byte("offset|-1"))
+	if _, err := DecodeOffsetCursor(negative); err != ErrInvalidCursor {
+		t.Fatalf("expected ErrInvalidCursor for negative offset, got %v", err)
+	}
+}
@@ -0,0 +1,50 @@
+package storage
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLocalStorage(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "media")
+	s, err := NewLocalStorage(dir, "/app/assets/media/")
+	if err != nil {
+		t.Fatalf("NewLocalStorage error: %v", err)
+	}
+	ctx := context.Background()
+
+	if err := s.Save(ctx, "abc.png", strings.NewReader("image bytes")); err != nil {
+		t.Fatalf("Save error: %v", err)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, "abc.png"))
+	if err != nil {
+		t.Fatalf("expected saved file: %v", err)
+	}
+	if string(data) != "image bytes" {
+		t.Fatalf("unexpected file contents %q", data)
+	}
+	if url := s.URL("abc.png"); url != "/app/assets/media/abc.png" {
+		t.Fatalf("unexpected URL %q", url)
+	}
+
+	// Keys must not escape the storage directory
+	for _, bad := range []string{"", "../evil.png", "sub/dir.png", ".hidden"} {
+		if err := s.Save(ctx, bad, strings.NewReader("x")); err == nil {
+			t.Fatalf("expected error saving key %q", bad)
+		}
+	}
+
+	if err := s.Delete(ctx, "abc.png"); err != nil {
+		t.Fatalf("Delete error: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "abc.png")); !os.IsNotExist(err) {
+		t.Fatalf("expected file to be removed")
+	}
+	// Deleting twice is not an error
+	if err := s.Delete(ctx, "abc.png"); err != nil {
+		t.Fatalf("Delete of missing file error: %v", err)
+	}
+}
@@ -0,0 +1,33 @@
+package textparse
+
+import (
+	"regexp"
+	"strings"
+)
+
+const MaxHashtagLength = 50
+
+// A hashtag starts with # at the beginning of the body or after a character
+// that cannot be part of a word, so "a#b" and "&#39;" are not tags.
+var hashtagRe = regexp.MustCompile(`(?:^|[^\p{L}\p{N}_&#])#([\p{L}\p{N}_]+)`)
+
+// ExtractHashtags returns the distinct hashtags in body, lowercased and
+// without the leading #, in order of first appearance.
+func ExtractHashtags(body string) []string {
+	tags := []string{}
+	seen := map[string]bool{}
+	for _, match := range hashtagRe.FindAllStringSubmatch(body, -1) {
+		tag := strings.ToLower(match[1])
+		if len([]rune(tag)) > MaxHashtagLength || seen[tag] {
+			continue
+		}
+		seen[tag] = true
+		tags = append(tags, tag)
+	}
+	return tags
+}
+
+// NormalizeHashtag turns user input such as "#Go" into the stored form "go".
+func NormalizeHashtag(tag string) string {
+	return strings.ToLower(strings.TrimPrefix(strings.TrimSpace(tag), "#"))
+}
@@ -0,0 +1,40 @@
+package textparse
+
+import (
+	"regexp"
+	"strings"
+)
+
+var (
+	// Handles are 3 to 30 lowercase letters, digits or underscores.
+	handleRe = regexp.MustCompile(`^[a-z0-9_]{3,30}$`)
+	// The lookbehind class keeps email addresses such as bob@example.com
+	// from being read as a mention of "example".
+	mentionRe = regexp.MustCompile(`(?:^|[^\p{L}\p{N}_.@])@([A-Za-z0-9_]+)`)
+)
+
+// NormalizeHandle lowercases a handle and strips a leading @.
+func NormalizeHandle(handle string) string {
+	return strings.ToLower(strings.TrimPrefix(strings.TrimSpace(handle), "@"))
+}
+
+// ValidHandle reports whether handle, already normalized, can be stored.
+func ValidHandle(handle string) bool {
+	return handleRe.MatchString(handle)
+}
+
+// ExtractMentions returns the distinct, normalized handles mentioned in body
+// in order of first appearance.
+func ExtractMentions(body string) []string {
+	handles := []string{}
+	seen := map[string]bool{}
+	for _, match := range mentionRe.FindAllStringSubmatch(body, -1) {
+		handle := NormalizeHandle(match[1])
+		if !ValidHandle(handle) || seen[handle] {
+			continue
+		}
+		seen[handle] = true
+		handles = append(handles, handle)
+	}
+	return handles
+}
@@ -0,0 +1,63 @@
+package textparse
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestExtractHashtags(t *testing.T) {
+	cases := []struct {
+		body string
+		want []string
+	}{
+		{"no tags here", []string{}},
+		{"#Go is fun #golang", []string{"go", "golang"}},
+		{"dupes #go #GO #Go", []string{"go"}},
+		{"email@x.com a#b &#39; ##double", []string{}},
+		{"(#paren) end.#dot", []string{"paren", "dot"}},
+		{"unicode #café", []string{"café"}},
+	}
+	for _, c := range cases {
+		got := ExtractHashtags(c.body)
+		if !reflect.DeepEqual(got, c.want) {
+			t.Fatalf("ExtractHashtags(%q) = %v, want %v", c.body, got, c.want)
+		}
+	}
+}
+
+func TestNormalizeHashtag(t *testing.T) {
+	if got := NormalizeHashtag(" #GoLang "); got != "golang" {
+		t.Fatalf("expected golang, got %q", got)
+	}
+}
+
+func TestExtractMentions(t *testing.T) {
+	cases := []struct {
+		body string
+		want []string
+	}{
+		{"hello world", []string{}},
+		{"@Alice and @bob_2, meet @alice", []string{"alice", "bob_2"}},
+		{"mail me at bob@example.com", []string{}},
+		{"too short @ab, ok @abc", []string{"abc"}},
+	}
+	for _, c := range cases {
+		got := ExtractMentions(c.body)
+		if !reflect.DeepEqual(got, c.want) {
+			t.Fatalf("ExtractMentions(%q) = %v, want %v", c.body, got, c.want)
+		}
+	}
+}
+
+func TestValidHandle(t *testing.T) {
+	for _, ok := range []string{"abc", "chirpy_fan_99"} {
+		if !ValidHandle(ok) {
+			t.Fatalf("expected %q to be valid", ok)
+		}
+	}
+	for _, bad := range []string{"", "ab", "Has-Dash", "this_handle_is_much_too_long_to_store"} {
+		if ValidHandle(bad) {
+			t.Fatalf("expected %q to be invalid", bad)
+		}
+	}
+}
@@ -0,0 +1,34 @@
+package webhook
+
+import (
+	"time"
+
+	"github.com/A-X-Z-Y-T-E/Chirpy/internal/jobs"
+)
+
+// Outcome is where a delivery stands after an attempt.
+type Outcome int
+
+const (
+	OutcomeDelivered Outcome = iota
+	OutcomeRetry
+	OutcomeDead
+)
+
+// RetryPolicy decides what becomes of a delivery after each attempt.
+type RetryPolicy struct {
+	MaxAttempts int
+	Backoff     jobs.Backoff
+}
+
+// Next returns the outcome of attempt, counting from 1, given the error
+// Send returned for it. For OutcomeRetry it also returns when to try again.
+func (p RetryPolicy) Next(attempt int, err error, now time.Time) (Outcome, time.Time) {
+	if err == nil {
+		return OutcomeDelivered, time.Time{}
+	}
+	if attempt >= p.MaxAttempts {
+		return OutcomeDead, time.Time{}
+	}
+	return OutcomeRetry, now.Add(p.Backoff.Delay(attempt))
+}
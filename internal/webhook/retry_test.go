@@ -0,0 +1,50 @@
+package webhook
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/A-X-Z-Y-T-E/Chirpy/internal/jobs"
+)
+
+func TestRetryPolicyNext(t *testing.T) {
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "down", http.StatusServiceUnavailable)
+	}))
+	defer failing.Close()
+	working := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer working.Close()
+
+	policy := RetryPolicy{MaxAttempts: 10, Backoff: jobs.Backoff{Base: 30 * time.Second, Max: 6 * time.Hour}}
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name     string
+		url      string
+		attempt  int
+		want     Outcome
+		wantNext time.Time
+	}{
+		{"503 on the first attempt is retried", failing.URL, 1, OutcomeRetry, now.Add(30 * time.Second)},
+		{"503 backs off further each time", failing.URL, 3, OutcomeRetry, now.Add(2 * time.Minute)},
+		{"503 on the last attempt is dead", failing.URL, 10, OutcomeDead, time.Time{}},
+		{"success is delivered", working.URL, 1, OutcomeDelivered, time.Time{}},
+		{"success on the last attempt is still delivered", working.URL, 10, OutcomeDelivered, time.Time{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewSender(nil).Send(context.Background(), Delivery{URL: tt.url, Secret: "s", Body: []byte("{}")})
+			got, next := policy.Next(tt.attempt, err, now)
+			if got != tt.want {
+				t.Errorf("outcome = %d, want %d", got, tt.want)
+			}
+			if !next.Equal(tt.wantNext) {
+				t.Errorf("next attempt = %v, want %v", next, tt.wantNext)
+			}
+		})
+	}
+}
@@ -0,0 +1,95 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Headers on every outbound delivery. Receivers verify HeaderSignature
+// against HeaderTimestamp and the raw body with a Verifier.
+const (
+	HeaderEvent     = "Chirpy-Event"
+	HeaderDelivery  = "Chirpy-Delivery"
+	HeaderTimestamp = "Chirpy-Timestamp"
+	HeaderSignature = "Chirpy-Signature"
+)
+
+const DefaultSendTimeout = 10 * time.Second
+
+// Delivery is one event on its way to one subscriber.
+type Delivery struct {
+	ID     string
+	Event  string
+	URL    string
+	Secret string
+	Body   []byte
+}
+
+// StatusError reports a subscriber that answered with something other than
+// a 2xx status.
+type StatusError struct {
+	StatusCode int
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("webhook: subscriber answered %d", e.StatusCode)
+}
+
+// Sender posts signed deliveries to subscribers.
+type Sender struct {
+	client *http.Client
+	now    func() time.Time
+}
+
+// NewSender sends with client, or with a client limited to
+// DefaultSendTimeout when client is nil.
+func NewSender(client *http.Client) *Sender {
+	if client == nil {
+		client = &http.Client{Timeout: DefaultSendTimeout}
+	}
+	return &Sender{client: client, now: time.Now}
+}
+
+// Send POSTs d.Body to d.URL signed with d.Secret. It returns the status
+// the subscriber answered with, or 0 if there was no answer, and a non-nil
+// error unless that status was 2xx.
+func (s *Sender) Send(ctx context.Context, d Delivery) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, d.URL, bytes.NewReader(d.Body))
+	if err != nil {
+		return 0, err
+	}
+	now := s.now()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(HeaderEvent, d.Event)
+	req.Header.Set(HeaderDelivery, d.ID)
+	req.Header.Set(HeaderTimestamp, strconv.FormatInt(now.Unix(), 10))
+	req.Header.Set(HeaderSignature, Sign(now, d.Body, d.Secret))
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	// Drain a little of the body so the connection can be reused.
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, &StatusError{StatusCode: resp.StatusCode}
+	}
+	return resp.StatusCode, nil
+}
+
+// NewSecret generates a signing secret for a new subscriber.
+func NewSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return "whsec_" + hex.EncodeToString(b), nil
+}
@@ -0,0 +1,87 @@
+package webhook
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSend(t *testing.T) {
+	secret, err := NewSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	verifier := NewVerifier(time.Minute, secret)
+	var got *http.Request
+	var gotBody []byte
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r
+		gotBody, _ = io.ReadAll(r.Body)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	body := []byte(`{"event":"chirp.created"}`)
+	status, err := NewSender(nil).Send(context.Background(), Delivery{
+		ID:     "d1",
+		Event:  "chirp.created",
+		URL:    srv.URL,
+		Secret: secret,
+		Body:   body,
+	})
+	if err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	if status != http.StatusNoContent {
+		t.Errorf("status = %d, want 204", status)
+	}
+	if string(gotBody) != string(body) {
+		t.Errorf("body = %q, want %q", gotBody, body)
+	}
+	if got.Header.Get(HeaderEvent) != "chirp.created" || got.Header.Get(HeaderDelivery) != "d1" {
+		t.Errorf("unexpected headers %v", got.Header)
+	}
+	if err := verifier.Verify(got.Header.Get(HeaderTimestamp), got.Header.Get(HeaderSignature), gotBody); err != nil {
+		t.Errorf("receiver could not verify the delivery: %v", err)
+	}
+}
+
+func TestSendStatusError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "nope", http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	status, err := NewSender(nil).Send(context.Background(), Delivery{URL: srv.URL, Secret: "s", Body: []byte("{}")})
+	var statusErr *StatusError
+	if !errors.As(err, &statusErr) || statusErr.StatusCode != http.StatusServiceUnavailable {
+		t.Fatalf("expected a 503 StatusError, got %v", err)
+	}
+	if status != http.StatusServiceUnavailable {
+		t.Errorf("status = %d, want 503", status)
+	}
+}
+
+func TestSendUnreachable(t *testing.T) {
+	srv := httptest.NewServer(http.NotFoundHandler())
+	url := srv.URL
+	srv.Close()
+
+	status, err := NewSender(nil).Send(context.Background(), Delivery{URL: url, Secret: "s", Body: []byte("{}")})
+	if err == nil || status != 0 {
+		t.Fatalf("expected a transport error and no status, got %d, %v", status, err)
+	}
+}
+
+func TestNewSecret(t *testing.T) {
+	a, _ := NewSecret()
+	b, _ := NewSecret()
+	if a == b || !strings.HasPrefix(a, "whsec_") {
+		t.Fatalf("unexpected secrets %q, %q", a, b)
+	}
+}
@@ -0,0 +1,43 @@
+package webhook
+
+import (
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestVerify(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	body := []byte(`{"id":"evt_1","event":"user.upgraded"}`)
+	v := NewVerifier(5*time.Minute, "new-secret", "old-secret")
+	v.now = func() time.Time { return now }
+	ts := strconv.FormatInt(now.Unix(), 10)
+
+	cases := []struct {
+		name      string
+		timestamp string
+		sig       string
+		body      []byte
+		want      error
+	}{
+		{"current secret", ts, Sign(now, body, "new-secret"), body, nil},
+		{"previous secret", ts, Sign(now, body, "old-secret"), body, nil},
+		{"signed with both", ts, Sign(now, body, "retired", "new-secret"), body, nil},
+		{"unknown secret", ts, Sign(now, body, "retired"), body, ErrInvalidSignature},
+		{"tampered body", ts, Sign(now, body, "new-secret"), []byte(`{"id":"evt_2"}`), ErrInvalidSignature},
+		{"timestamp swapped", strconv.FormatInt(now.Unix()-1, 10), Sign(now, body, "new-secret"), body, ErrInvalidSignature},
+		{"too old", strconv.FormatInt(now.Add(-6*time.Minute).Unix(), 10), Sign(now.Add(-6*time.Minute), body, "new-secret"), body, ErrStaleTimestamp},
+		{"too far ahead", strconv.FormatInt(now.Add(6*time.Minute).Unix(), 10), Sign(now.Add(6*time.Minute), body, "new-secret"), body, ErrStaleTimestamp},
+		{"missing signature", ts, "", body, ErrMissingSignature},
+		{"missing timestamp", "", Sign(now, body, "new-secret"), body, ErrMissingSignature},
+		{"bad version", ts, "v0=deadbeef", body, ErrInvalidSignature},
+		{"bad hex", ts, "v1=zz", body, ErrInvalidSignature},
+	}
+	for _, tc := range cases {
+		err := v.Verify(tc.timestamp, tc.sig, tc.body)
+		if !errors.Is(err, tc.want) {
+			t.Fatalf("%s: expected %v, got %v", tc.name, tc.want, err)
+		}
+	}
+}
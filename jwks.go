@@ -0,0 +1,18 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// JWKS publishes the public signing keys so other services can verify
+// Chirpy access tokens without sharing a secret. Retired keys stay listed
+// for as long as they are kept in the keyring.
+func (cfg *apiConfig) JWKS() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "public, max-age=300")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(cfg.keys.JWKS())
+	})
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"github.com/A-X-Z-Y-T-E/Chirpy/internal/auth"
+	"github.com/A-X-Z-Y-T-E/Chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+// viewerID returns the user OptionalAuth found on the request, if any.
+func (cfg *apiConfig) viewerID(req *http.Request) uuid.NullUUID {
+	user, ok := auth.UserFromContext(req.Context())
+	if !ok {
+		return uuid.NullUUID{}
+	}
+	return uuid.NullUUID{UUID: user.ID, Valid: true}
+}
+
+// likeStats loads like counts for a batch of chirps in one query.
+func (cfg *apiConfig) likeStats(ctx context.Context, viewer uuid.NullUUID, chirpIDs []uuid.UUID) (map[uuid.UUID]database.GetChirpLikeStatsRow, error) {
+	rows, err := cfg.db.GetChirpLikeStats(ctx, database.GetChirpLikeStatsParams{
+		ViewerID: viewer,
+		ChirpIds: chirpIDs,
+	})
+	if err != nil {
+		return nil, err
+	}
+	stats := make(map[uuid.UUID]database.GetChirpLikeStatsRow, len(rows))
+	for _, row := range rows {
+		stats[row.ChirpID] = row
+	}
+	return stats, nil
+}
+
+func applyLikeStats(out *Chirp, viewer uuid.NullUUID, stats database.GetChirpLikeStatsRow) {
+	out.Like_count = stats.LikeCount
+	if viewer.Valid {
+		liked := stats.LikedByMe
+		out.Liked_by_me = &liked
+	}
+}
+
+func (cfg *apiConfig) LikeChirp() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		user, _ := auth.UserFromContext(r.Context())
+		UserID := user.ID
+
+		ChirpID, err := convert_to_uuid(r.PathValue("chirpID"))
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(struct {
+				Error string `json:"error"`
+			}{
+				Error: "Invalid chirp ID",
+			})
+			return
+		}
+		if _, err := cfg.db.GetChirpByID(r.Context(), ChirpID); err != nil {
+			w.WriteHeader(http.StatusNotFound)
+			json.NewEncoder(w).Encode(struct {
+				Error string `json:"error"`
+			}{
+				Error: "Chirp not found",
+			})
+			return
+		}
+
+		err = cfg.db.LikeChirp(r.Context(), database.LikeChirpParams{
+			ChirpID: ChirpID,
+			UserID:  UserID,
+		})
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(struct {
+				Error string `json:"error"`
+			}{
+				Error: "Something went wrong",
+			})
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
+
+func (cfg *apiConfig) UnlikeChirp() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		user, _ := auth.UserFromContext(r.Context())
+		UserID := user.ID
+
+		ChirpID, err := convert_to_uuid(r.PathValue("chirpID"))
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(struct {
+				Error string `json:"error"`
+			}{
+				Error: "Invalid chirp ID",
+			})
+			return
+		}
+
+		err = cfg.db.UnlikeChirp(r.Context(), database.UnlikeChirpParams{
+			ChirpID: ChirpID,
+			UserID:  UserID,
+		})
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(struct {
+				Error string `json:"error"`
+			}{
+				Error: "Something went wrong",
+			})
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
@@ -0,0 +1,334 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/A-X-Z-Y-T-E/Chirpy/internal/database"
+	"github.com/A-X-Z-Y-T-E/Chirpy/internal/lockout"
+	"github.com/A-X-Z-Y-T-E/Chirpy/internal/mailer"
+	"github.com/A-X-Z-Y-T-E/Chirpy/internal/pagination"
+	"github.com/google/uuid"
+)
+
+type LockoutEvent struct {
+	ID          string `json:"id"`
+	UserID      string `json:"user_id,omitempty"`
+	Key         string `json:"key"`
+	IpAddress   string `json:"ip_address"`
+	Failures    int32  `json:"failures"`
+	LockedUntil string `json:"locked_until"`
+	CreatedAt   string `json:"created_at"`
+	UnlockedAt  string `json:"unlocked_at,omitempty"`
+}
+
+func emailThrottleKey(email string) string {
+	return "email:" + strings.ToLower(strings.TrimSpace(email))
+}
+
+func ipThrottleKey(r *http.Request) string {
+	return "ip:" + clientIP(r)
+}
+
+func mfaThrottleKey(userID uuid.UUID) string {
+	return "mfa:" + userID.String()
+}
+
+func verificationThrottleKey(userID uuid.UUID) string {
+	return "verify:" + userID.String()
+}
+
+// loginLocked answers 429 if any of keys is locked out. Lookup errors let
+// the request through; the password check behind it still applies.
+func (cfg *apiConfig) loginLocked(w http.ResponseWriter, r *http.Request, keys ...string) bool {
+	return cfg.throttled(w, r, "Too many failed login attempts", keys...)
+}
+
+// throttled answers 429 with msg and a Retry-After if any of keys is
+// locked, and reports whether it did.
+func (cfg *apiConfig) throttled(w http.ResponseWriter, r *http.Request, msg string, keys ...string) bool {
+	locks, err := cfg.db.GetActiveLockouts(r.Context(), keys)
+	if err != nil {
+		fmt.Println("GetActiveLockouts error:", err)
+		return false
+	}
+	var until time.Time
+	for _, lock := range locks {
+		if lock.LockedUntil.Time.After(until) {
+			until = lock.LockedUntil.Time
+		}
+	}
+	retryAfter := lockout.RetryAfter(until, time.Now())
+	if retryAfter == 0 {
+		return false
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+	w.WriteHeader(http.StatusTooManyRequests)
+	json.NewEncoder(w).Encode(struct {
+		Error      string `json:"error"`
+		RetryAfter int    `json:"retry_after"`
+	}{
+		Error:      msg,
+		RetryAfter: retryAfter,
+	})
+	return true
+}
+
+// throttle is one counter that login attempts are charged to.
+type throttle struct {
+	key    string
+	policy lockout.Policy
+	// shared counters cover more than one account, so a successful login
+	// only takes back its own attempt instead of clearing the count.
+	shared bool
+}
+
+func accountThrottle(key string) throttle {
+	return throttle{key: key, policy: lockout.Email}
+}
+
+func ipThrottle(r *http.Request) throttle {
+	return throttle{key: ipThrottleKey(r), policy: lockout.IP, shared: true}
+}
+
+// loginAttempt is an attempt that has already been counted against its
+// throttles. failures and lockedUntil line up with throttles.
+type loginAttempt struct {
+	throttles   []throttle
+	failures    []int32
+	lockedUntil []time.Time
+}
+
+func newLoginAttempt(throttles []throttle) *loginAttempt {
+	return &loginAttempt{
+		throttles:   throttles,
+		failures:    make([]int32, len(throttles)),
+		lockedUntil: make([]time.Time, len(throttles)),
+	}
+}
+
+// startLoginAttempt counts an attempt against every throttle before the
+// password or code is checked, and answers 429 if any of them is locked.
+// Counting errors let the request through; the check behind it still
+// applies.
+func (cfg *apiConfig) startLoginAttempt(w http.ResponseWriter, r *http.Request, throttles ...throttle) (*loginAttempt, bool) {
+	keys := make([]string, len(throttles))
+	for i, t := range throttles {
+		keys[i] = t.key
+	}
+	if cfg.loginLocked(w, r, keys...) {
+		return nil, false
+	}
+	attempt, err := cfg.recordLoginAttempt(r.Context(), throttles...)
+	if errors.Is(err, sql.ErrNoRows) {
+		// Locked by a parallel attempt since the check above.
+		if !cfg.loginLocked(w, r, keys...) {
+			w.WriteHeader(http.StatusTooManyRequests)
+		}
+		return nil, false
+	}
+	if err != nil {
+		fmt.Println("recordLoginAttempt error:", err)
+	}
+	return attempt, true
+}
+
+// recordLoginAttempt counts an attempt against every throttle and locks
+// those that policy says to, all in one transaction, so parallel guesses
+// cannot all slip in under the limit. If any throttle is already locked it
+// fails with sql.ErrNoRows and none of them is charged. On other errors the
+// returned attempt counts nothing. Callers list account throttles before
+// shared ones so that transactions lock rows in the same order.
+func (cfg *apiConfig) recordLoginAttempt(ctx context.Context, throttles ...throttle) (*loginAttempt, error) {
+	uncounted := newLoginAttempt(throttles)
+	tx, err := cfg.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return uncounted, err
+	}
+	defer tx.Rollback()
+	q := cfg.db.WithTx(tx)
+
+	now := time.Now()
+	counted := newLoginAttempt(throttles)
+	for i, t := range throttles {
+		failures, err := q.RecordLoginAttempt(ctx, database.RecordLoginAttemptParams{
+			ThrottleKey: t.key,
+			WindowStart: now.Add(-t.policy.Window),
+		})
+		if err != nil {
+			return uncounted, err
+		}
+		counted.failures[i] = failures
+		if lockFor := t.policy.LockFor(int(failures)); lockFor > 0 {
+			counted.lockedUntil[i] = now.Add(lockFor)
+			err = q.LockLoginThrottle(ctx, database.LockLoginThrottleParams{
+				ThrottleKey: t.key,
+				LockedUntil: sql.NullTime{Time: counted.lockedUntil[i], Valid: true},
+			})
+			if err != nil {
+				return uncounted, err
+			}
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return uncounted, err
+	}
+	return counted, nil
+}
+
+// loginFailed records the lockouts this attempt caused. When user is known
+// the lockout of its own account is recorded against it and its owner is
+// told by email.
+func (cfg *apiConfig) loginFailed(r *http.Request, attempt *loginAttempt, user *database.User) {
+	for i, t := range attempt.throttles {
+		until := attempt.lockedUntil[i]
+		if until.IsZero() {
+			continue
+		}
+		var userID uuid.NullUUID
+		if user != nil && !t.shared {
+			userID = uuid.NullUUID{UUID: user.ID, Valid: true}
+		}
+		err := cfg.db.CreateLockoutEvent(r.Context(), database.CreateLockoutEventParams{
+			UserID:      userID,
+			ThrottleKey: t.key,
+			IpAddress:   clientIP(r),
+			Failures:    attempt.failures[i],
+			LockedUntil: until,
+		})
+		if err != nil {
+			fmt.Println("CreateLockoutEvent error:", err)
+		}
+		if userID.Valid {
+			cfg.sendMail(mailer.Message{
+				To:      user.Email,
+				Subject: "Sign-in to your Chirpy account was paused",
+				Body: fmt.Sprintf("There were %d failed attempts to sign in to your Chirpy account, the last from %s.\n\n"+
+					"Signing in is paused until %s. If this wasn't you, consider resetting your password.\n",
+					attempt.failures[i], clientIP(r), until.UTC().Format(time.RFC1123)),
+			})
+		}
+	}
+}
+
+// loginSucceeded resets the account's counters and takes the attempt back
+// from shared ones, lifting a lock only this attempt put on them.
+func (cfg *apiConfig) loginSucceeded(r *http.Request, attempt *loginAttempt) {
+	for _, t := range attempt.throttles {
+		if !t.shared {
+			if err := cfg.db.ClearLoginThrottle(r.Context(), t.key); err != nil {
+				fmt.Println("ClearLoginThrottle error:", err)
+			}
+			continue
+		}
+		err := cfg.db.ForgiveLoginAttempt(r.Context(), database.ForgiveLoginAttemptParams{
+			Threshold:   int32(t.policy.Threshold),
+			ThrottleKey: t.key,
+		})
+		if err != nil {
+			fmt.Println("ForgiveLoginAttempt error:", err)
+		}
+	}
+}
+
+// ListLockouts shows the most recent lockouts for admins.
+func (cfg *apiConfig) ListLockouts() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		limit, err := pagination.ParseLimit(r.URL.Query().Get("limit"))
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(struct {
+				Error string `json:"error"`
+			}{
+				Error: err.Error(),
+			})
+			return
+		}
+		events, err := cfg.db.ListLockoutEvents(r.Context(), limit)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(struct {
+				Error string `json:"error"`
+			}{
+				Error: "Internal Server Error.",
+			})
+			return
+		}
+
+		out := []LockoutEvent{}
+		for _, event := range events {
+			item := LockoutEvent{
+				ID:          event.ID.String(),
+				Key:         event.ThrottleKey,
+				IpAddress:   event.IpAddress,
+				Failures:    event.Failures,
+				LockedUntil: event.LockedUntil.String(),
+				CreatedAt:   event.CreatedAt.String(),
+			}
+			if event.UserID.Valid {
+				item.UserID = event.UserID.UUID.String()
+			}
+			if event.UnlockedAt.Valid {
+				item.UnlockedAt = event.UnlockedAt.Time.String()
+			}
+			out = append(out, item)
+		}
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(out)
+	})
+}
+
+// UnlockUser lifts the password and two-factor lockouts on an account. The
+// IP lockout is left alone since it may cover other accounts.
+func (cfg *apiConfig) UnlockUser() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		UserID, err := convert_to_uuid(r.PathValue("userID"))
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(struct {
+				Error string `json:"error"`
+			}{
+				Error: "Invalid user ID",
+			})
+			return
+		}
+		user, err := cfg.db.GetUserFromId(r.Context(), UserID)
+		if err != nil {
+			w.WriteHeader(http.StatusNotFound)
+			json.NewEncoder(w).Encode(struct {
+				Error string `json:"error"`
+			}{
+				Error: "User not found",
+			})
+			return
+		}
+		for _, key := range []string{emailThrottleKey(user.Email), mfaThrottleKey(user.ID)} {
+			if err := cfg.db.ClearLoginThrottle(r.Context(), key); err != nil {
+				fmt.Println("ClearLoginThrottle error:", err)
+				w.WriteHeader(http.StatusInternalServerError)
+				json.NewEncoder(w).Encode(struct {
+					Error string `json:"error"`
+				}{
+					Error: "Something went wrong",
+				})
+				return
+			}
+		}
+		if err := cfg.db.UnlockUserLockouts(r.Context(), uuid.NullUUID{UUID: user.ID, Valid: true}); err != nil {
+			fmt.Println("UnlockUserLockouts error:", err)
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
@@ -0,0 +1,21 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/A-X-Z-Y-T-E/Chirpy/internal/mailer"
+)
+
+// sendMail delivers msg in the background so that a slow mail server never
+// holds up a response. Failures are only logged.
+func (cfg *apiConfig) sendMail(msg mailer.Message) {
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		if err := cfg.mailer.Send(ctx, msg); err != nil {
+			fmt.Println("mail error:", err)
+		}
+	}()
+}
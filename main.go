@@ -1,13 +1,21 @@
 package main
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 	"net/http"
 	"os"
+	"strings"
 	"sync/atomic"
 
+	"github.com/A-X-Z-Y-T-E/Chirpy/internal/auth"
+	"github.com/A-X-Z-Y-T-E/Chirpy/internal/contentfilter"
 	"github.com/A-X-Z-Y-T-E/Chirpy/internal/database"
+	"github.com/A-X-Z-Y-T-E/Chirpy/internal/jobs"
+	"github.com/A-X-Z-Y-T-E/Chirpy/internal/mailer"
+	"github.com/A-X-Z-Y-T-E/Chirpy/internal/storage"
+	"github.com/A-X-Z-Y-T-E/Chirpy/internal/webhook"
 	"github.com/joho/godotenv"
 	_ "github.com/lib/pq"
 )
@@ -15,9 +23,27 @@ import (
 type apiConfig struct {
 	fileServerHits atomic.Int32
 	db             *database.Queries
+	// conn is the pool behind db, for the few writes that need a
+	// transaction.
+	conn           *sql.DB
 	platform       string
 	secret         string
+	keys           *auth.Keyring
+	authn          *auth.Authenticator
 	Polka_key      string
+	polkaVerifier  *webhook.Verifier
+	webhookTrigger jobs.Trigger
+	media          storage.Storage
+	contentFilter  contentfilter.Filter
+	mailer         mailer.Mailer
+	baseURL        string
+	// requireVerifiedEmail blocks posting chirps until the author has
+	// confirmed their email (REQUIRE_VERIFIED_EMAIL=true).
+	requireVerifiedEmail bool
+	// Outbound webhooks: deliveryTrigger wakes the delivery worker when
+	// an event is queued.
+	webhookSender   *webhook.Sender
+	deliveryTrigger jobs.Trigger
 }
 
 func (cfg *apiConfig) middlewareMetricsInc(next http.Handler) http.Handler {
@@ -40,6 +66,82 @@ func (cfg *apiConfig) printMetrics() http.Handler {
 	})
 }
 
+// loadContentFilter builds the chirp body filter chain from the optional
+// BANNED_WORDS_FILE and BLOCK_PATTERNS_FILE lists. Further filters are added
+// by appending to the chain.
+func loadContentFilter() (contentfilter.Chain, error) {
+	chain := contentfilter.Chain{}
+	if path := os.Getenv("BANNED_WORDS_FILE"); path != "" {
+		banned, err := contentfilter.LoadBannedWords(path)
+		if err != nil {
+			return nil, err
+		}
+		chain = append(chain, banned)
+	}
+	if path := os.Getenv("BLOCK_PATTERNS_FILE"); path != "" {
+		block, err := contentfilter.LoadBlockPatterns(path)
+		if err != nil {
+			return nil, err
+		}
+		chain = append(chain, block)
+	}
+	return chain, nil
+}
+
+// loadKeyring signs tokens with the PEM keys in JWT_KEYS_DIR when it is set,
+// keeping the legacy tokenSecret around for verification only. Without it
+// tokens stay HS256 with tokenSecret.
+func loadKeyring(secret string) (*auth.Keyring, error) {
+	dir := os.Getenv("JWT_KEYS_DIR")
+	if dir == "" {
+		return auth.NewKeyring(auth.NewHMACKey("", secret))
+	}
+	keys, err := auth.LoadKeyring(dir, os.Getenv("JWT_CURRENT_KID"))
+	if err != nil {
+		return nil, err
+	}
+	if secret != "" {
+		if err := keys.Add(auth.NewHMACKey("", secret)); err != nil {
+			return nil, err
+		}
+	}
+	return keys, nil
+}
+
+// loadMailer sends through SMTP_ADDR when it is set. Otherwise mail is
+// written to MAIL_DIR (./mail by default) for local development.
+func loadMailer() (mailer.Mailer, error) {
+	from := os.Getenv("MAIL_FROM")
+	if from == "" {
+		from = "Chirpy <no-reply@chirpy.local>"
+	}
+	if addr := os.Getenv("SMTP_ADDR"); addr != "" {
+		return mailer.NewSMTPMailer(addr, from, os.Getenv("SMTP_USERNAME"), os.Getenv("SMTP_PASSWORD")), nil
+	}
+	dir := os.Getenv("MAIL_DIR")
+	if dir == "" {
+		dir = "./mail"
+	}
+	return mailer.NewFileMailer(dir, from)
+}
+
+// loadPolkaVerifier reads POLKA_WEBHOOK_SECRETS, a comma-separated list so
+// that a new secret can be added before the old one is removed. It returns
+// nil when the list is empty, leaving webhooks on the legacy API key.
+func loadPolkaVerifier() *webhook.Verifier {
+	var secrets []string
+	for _, secret := range strings.Split(os.Getenv("POLKA_WEBHOOK_SECRETS"), ",") {
+		if secret = strings.TrimSpace(secret); secret != "" {
+			secrets = append(secrets, secret)
+		}
+	}
+	if len(secrets) == 0 {
+		fmt.Println("Warning: POLKA_WEBHOOK_SECRETS is not set; Polka webhooks are checked against POLKA_KEY only and are not signed")
+		return nil
+	}
+	return webhook.NewVerifier(polkaTolerance, secrets...)
+}
+
 func main() {
 	err := godotenv.Load()
 	dbURL := os.Getenv("DB_URL")
@@ -58,25 +160,88 @@ func main() {
 		Addr:    "localhost:8080",
 		Handler: mux,
 	}
+	// Uploaded media is written under ./assets so the /app/ file server
+	// already serves it.
+	media, err := storage.NewLocalStorage("./assets/media", "/app/assets/media")
+	if err != nil {
+		fmt.Println("Couldnt create media storage:", err)
+		return
+	}
+	contentFilter, err := loadContentFilter()
+	if err != nil {
+		fmt.Println("Couldnt load content filter:", err)
+		return
+	}
+	keys, err := loadKeyring(os.Getenv("tokenSecret"))
+	if err != nil {
+		fmt.Println("Couldnt load JWT keys:", err)
+		return
+	}
+	mail, err := loadMailer()
+	if err != nil {
+		fmt.Println("Couldnt create mailer:", err)
+		return
+	}
+	baseURL := os.Getenv("BASE_URL")
+	if baseURL == "" {
+		baseURL = "http://" + server.Addr
+	}
 	apiCfg := apiConfig{
 		fileServerHits: atomic.Int32{},
 		db:             dbQueries,
+		conn:           db,
 		platform:       os.Getenv("PLATFORM"),
 		secret:         os.Getenv("tokenSecret"),
+		keys:           keys,
+		authn:          auth.NewAuthenticator(keys, dbQueries),
 		Polka_key:      os.Getenv("POLKA_KEY"),
+		polkaVerifier:  loadPolkaVerifier(),
+		webhookTrigger: jobs.NewTrigger(),
+		media:          media,
+		contentFilter:  contentFilter,
+		mailer:         mail,
+		baseURL:        baseURL,
+
+		requireVerifiedEmail: os.Getenv("REQUIRE_VERIFIED_EMAIL") == "true",
+		webhookSender:        webhook.NewSender(nil),
+		deliveryTrigger:      jobs.NewTrigger(),
 	}
 
+	authn := apiCfg.authn
+
 	file_server_handler := http.StripPrefix("/app/", http.FileServer(http.Dir(".")))
 	assets_file_handler := http.StripPrefix("/app/assets", http.FileServer(http.Dir("./assets")))
 	mux.Handle("/app/", apiCfg.middlewareMetricsInc(file_server_handler))
 	mux.Handle("/app/assets", assets_file_handler)
 
-	mux.Handle("GET /admin/metrics", apiCfg.printMetrics())
-	mux.Handle("POST /admin/reset", apiCfg.Reset())
+	mux.Handle("GET /admin/metrics", authn.RequireRole(auth.RoleAdmin, apiCfg.printMetrics()))
+	mux.Handle("POST /admin/reset", authn.RequireRole(auth.RoleAdmin, apiCfg.Reset()))
+	mux.Handle("PUT /admin/users/{userID}/role", authn.RequireRole(auth.RoleAdmin, apiCfg.SetRole()))
+	mux.Handle("GET /admin/lockouts", authn.RequireRole(auth.RoleAdmin, apiCfg.ListLockouts()))
+	mux.Handle("POST /admin/users/{userID}/unlock", authn.RequireRole(auth.RoleAdmin, apiCfg.UnlockUser()))
+	mux.Handle("GET /admin/webhooks", authn.RequireRole(auth.RoleAdmin, apiCfg.ListWebhookEvents()))
+	mux.Handle("GET /admin/webhooks/{eventID}", authn.RequireRole(auth.RoleAdmin, apiCfg.GetWebhookEvent()))
+	mux.Handle("POST /admin/webhooks/{eventID}/replay", authn.RequireRole(auth.RoleAdmin, apiCfg.ReplayWebhookEvent()))
+	mux.Handle("GET /admin/webhook-subscriptions", authn.RequireRole(auth.RoleAdmin, apiCfg.ListWebhookSubscriptions()))
+	mux.Handle("POST /admin/webhook-subscriptions", authn.RequireRole(auth.RoleAdmin, apiCfg.CreateWebhookSubscription()))
+	mux.Handle("DELETE /admin/webhook-subscriptions/{subscriptionID}", authn.RequireRole(auth.RoleAdmin, apiCfg.DeleteWebhookSubscription()))
+	mux.Handle("GET /admin/webhook-subscriptions/{subscriptionID}/deliveries", authn.RequireRole(auth.RoleAdmin, apiCfg.ListWebhookDeliveries()))
+	mux.Handle("POST /admin/webhook-deliveries/{deliveryID}/redeliver", authn.RequireRole(auth.RoleAdmin, apiCfg.RedeliverWebhookDelivery()))
 
 	//API
-	mux.Handle("GET /api/chirps", apiCfg.ReturnChirps())
-	mux.Handle("GET /api/chirps/{chirpID}", apiCfg.GetChirp())
+	mux.Handle("GET /api/chirps", authn.OptionalAuth(apiCfg.ReturnChirps()))
+	mux.Handle("GET /api/chirps/search", authn.OptionalAuth(apiCfg.SearchChirps()))
+	mux.Handle("GET /api/chirps/{chirpID}", authn.OptionalAuth(apiCfg.GetChirp()))
+	mux.Handle("GET /api/chirps/{chirpID}/thread", authn.OptionalAuth(apiCfg.GetThread()))
+	mux.Handle("GET /api/chirps/{chirpID}/revisions", apiCfg.ListRevisions())
+	mux.Handle("GET /api/users/{userID}/followers", apiCfg.ListFollows(true))
+	mux.Handle("GET /api/users/{userID}/following", apiCfg.ListFollows(false))
+	mux.Handle("GET /api/timeline", authn.RequireAuth(apiCfg.Timeline()))
+	mux.Handle("GET /api/users/me/mentions", authn.RequireAuth(apiCfg.ListMyMentions()))
+	mux.Handle("GET /api/sessions", authn.RequireAuth(apiCfg.ListSessions()))
+	mux.Handle("GET /api/hashtags/trending", apiCfg.TrendingHashtags())
+	mux.Handle("GET /api/hashtags/{tag}/chirps", authn.OptionalAuth(apiCfg.HashtagChirps()))
+	mux.Handle("GET /.well-known/jwks.json", apiCfg.JWKS())
 	mux.HandleFunc("GET /api/healthz", func(w http.ResponseWriter, req *http.Request) {
 		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
 		w.WriteHeader(http.StatusOK)
@@ -84,15 +249,35 @@ func main() {
 	})
 
 	mux.Handle("POST /api/polka/webhooks", apiCfg.Upgrade_User())
-	mux.Handle("POST /api/chirps", apiCfg.add_chirp())
+	mux.Handle("POST /api/chirps", authn.RequireAuth(apiCfg.add_chirp()))
 	mux.Handle("POST /api/users", apiCfg.create_user())
 	mux.Handle("POST /api/login", apiCfg.login())
+	mux.Handle("POST /api/login/2fa", apiCfg.LoginTwoFactor())
+	mux.Handle("POST /api/users/me/2fa", authn.RequireAuth(apiCfg.EnrollTwoFactor()))
+	mux.Handle("POST /api/users/me/2fa/confirm", authn.RequireAuth(apiCfg.ConfirmTwoFactor()))
 	mux.Handle("POST /api/refresh", apiCfg.refresh())
 	mux.Handle("POST /api/revoke", apiCfg.revoke())
+	mux.Handle("POST /api/password-reset/request", apiCfg.RequestPasswordReset())
+	mux.Handle("POST /api/password-reset/confirm", apiCfg.ConfirmPasswordReset())
+	mux.Handle("POST /api/email/verify", apiCfg.VerifyEmail())
+	mux.Handle("POST /api/email/verify/resend", authn.RequireAuth(apiCfg.ResendVerification()))
+	mux.Handle("POST /api/sessions/revoke-all", authn.RequireAuth(apiCfg.RevokeAllSessions()))
+	mux.Handle("POST /api/users/{userID}/follow", authn.RequireAuth(apiCfg.Follow()))
+	mux.Handle("POST /api/chirps/{chirpID}/likes", authn.RequireAuth(apiCfg.LikeChirp()))
+	mux.Handle("POST /api/chirps/{chirpID}/media", authn.RequireAuth(apiCfg.UploadChirpMedia()))
+
+	mux.Handle("PUT /api/users", authn.RequireAuth(apiCfg.UpdateCredentials()))
+	mux.Handle("PUT /api/chirps/{chirpID}", authn.RequireAuth(apiCfg.UpdateChirp()))
 
-	mux.Handle("PUT /api/users", apiCfg.UpdateCredentials())
+	mux.Handle("DELETE /api/chirps/{chirpID}", authn.RequireAuth(apiCfg.DeleteUser()))
+	mux.Handle("DELETE /api/users/{userID}/follow", authn.RequireAuth(apiCfg.Unfollow()))
+	mux.Handle("DELETE /api/chirps/{chirpID}/likes", authn.RequireAuth(apiCfg.UnlikeChirp()))
+	mux.Handle("DELETE /api/sessions/{id}", authn.RequireAuth(apiCfg.RevokeSession()))
+	mux.Handle("DELETE /api/users/me/2fa", authn.RequireAuth(apiCfg.DisableTwoFactor()))
 
-	mux.Handle("DELETE /api/chirps/{chirpID}", apiCfg.DeleteUser())
+	go jobs.Every(context.Background(), "subscription expiry", subscriptionExpiryInterval, apiCfg.expireSubscriptions)
+	go jobs.Run(context.Background(), "webhook worker", webhookPollInterval, apiCfg.webhookTrigger, apiCfg.processWebhookEvents)
+	go jobs.Run(context.Background(), "webhook delivery", deliveryPollInterval, apiCfg.deliveryTrigger, apiCfg.deliverWebhooks)
 
 	err = server.ListenAndServe()
 	if err != nil {
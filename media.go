@@ -0,0 +1,229 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/A-X-Z-Y-T-E/Chirpy/internal/auth"
+	"github.com/A-X-Z-Y-T-E/Chirpy/internal/database"
+	"github.com/A-X-Z-Y-T-E/Chirpy/internal/entitlements"
+)
+
+const maxMediaBytes = 5 << 20
+
+// Uploads are identified by sniffing their first bytes, never by the
+// client-supplied Content-Type or file name.
+var allowedMediaTypes = map[string]string{
+	"image/png":  ".png",
+	"image/jpeg": ".jpg",
+	"image/gif":  ".gif",
+	"image/webp": ".webp",
+}
+
+type ChirpMedia struct {
+	ID           string `json:"id"`
+	URL          string `json:"url"`
+	Content_type string `json:"content_type"`
+}
+
+func mediaKey(ext string) (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b) + ext, nil
+}
+
+func (cfg *apiConfig) UploadChirpMedia() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		user, _ := auth.UserFromContext(r.Context())
+		UserID := user.ID
+
+		ChirpID, err := convert_to_uuid(r.PathValue("chirpID"))
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(struct {
+				Error string `json:"error"`
+			}{
+				Error: "Invalid chirp ID",
+			})
+			return
+		}
+		chirp, err := cfg.db.GetChirpByID(r.Context(), ChirpID)
+		if err != nil {
+			w.WriteHeader(http.StatusNotFound)
+			json.NewEncoder(w).Encode(struct {
+				Error string `json:"error"`
+			}{
+				Error: "Chirp not found",
+			})
+			return
+		}
+		if chirp.UserID != UserID {
+			w.WriteHeader(http.StatusForbidden)
+			json.NewEncoder(w).Encode(struct {
+				Error string `json:"error"`
+			}{
+				Error: "Forbidden",
+			})
+			return
+		}
+		count, err := cfg.db.CountChirpMedia(r.Context(), ChirpID)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(struct {
+				Error string `json:"error"`
+			}{
+				Error: "Something went wrong",
+			})
+			return
+		}
+		// Attachments are a Chirpy Red perk.
+		if err := entitlements.For(user.IsChirpyRed).CheckMedia(int(count)); err != nil {
+			writeEntitlementError(w, err)
+			return
+		}
+
+		// Leave some room for the multipart framing around the file itself.
+		r.Body = http.MaxBytesReader(w, r.Body, maxMediaBytes+1<<20)
+		file, _, err := r.FormFile("file")
+		if err != nil {
+			var maxErr *http.MaxBytesError
+			status, msg := http.StatusBadRequest, "Missing file"
+			if errors.As(err, &maxErr) {
+				status, msg = http.StatusRequestEntityTooLarge, "File is too large"
+			}
+			w.WriteHeader(status)
+			json.NewEncoder(w).Encode(struct {
+				Error string `json:"error"`
+			}{
+				Error: msg,
+			})
+			return
+		}
+		defer file.Close()
+
+		data, err := io.ReadAll(io.LimitReader(file, maxMediaBytes+1))
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(struct {
+				Error string `json:"error"`
+			}{
+				Error: "Could not read file",
+			})
+			return
+		}
+		if len(data) > maxMediaBytes {
+			w.WriteHeader(http.StatusRequestEntityTooLarge)
+			json.NewEncoder(w).Encode(struct {
+				Error string `json:"error"`
+			}{
+				Error: "File is too large",
+			})
+			return
+		}
+		contentType := http.DetectContentType(data)
+		ext, ok := allowedMediaTypes[contentType]
+		if !ok {
+			w.WriteHeader(http.StatusUnsupportedMediaType)
+			json.NewEncoder(w).Encode(struct {
+				Error string `json:"error"`
+			}{
+				Error: "Unsupported file type",
+			})
+			return
+		}
+
+		key, err := mediaKey(ext)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(struct {
+				Error string `json:"error"`
+			}{
+				Error: "Something went wrong",
+			})
+			return
+		}
+		if err := cfg.media.Save(r.Context(), key, bytes.NewReader(data)); err != nil {
+			fmt.Println("media Save error:", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(struct {
+				Error string `json:"error"`
+			}{
+				Error: "Something went wrong",
+			})
+			return
+		}
+		medium, err := cfg.createChirpMedia(r.Context(), entitlements.For(user.IsChirpyRed), database.CreateChirpMediaParams{
+			ChirpID:     ChirpID,
+			StorageKey:  key,
+			ContentType: contentType,
+			SizeBytes:   int64(len(data)),
+		})
+		var denied *entitlements.Denied
+		if errors.As(err, &denied) {
+			// Another upload took the last slot while this one was read.
+			cfg.media.Delete(r.Context(), key)
+			writeEntitlementError(w, err)
+			return
+		}
+		if err != nil {
+			cfg.media.Delete(r.Context(), key)
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(struct {
+				Error string `json:"error"`
+			}{
+				Error: "Something went wrong",
+			})
+			return
+		}
+
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(cfg.mediaJSON(medium))
+	})
+}
+
+// createChirpMedia records an attachment while holding a lock on its chirp,
+// so concurrent uploads cannot take the chirp past its media limit. The
+// count checked in UploadChirpMedia only spares a doomed upload.
+func (cfg *apiConfig) createChirpMedia(ctx context.Context, ent entitlements.Entitlements, arg database.CreateChirpMediaParams) (database.ChirpMedium, error) {
+	tx, err := cfg.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return database.ChirpMedium{}, err
+	}
+	defer tx.Rollback()
+	q := cfg.db.WithTx(tx)
+
+	if _, err := q.LockChirp(ctx, arg.ChirpID); err != nil {
+		return database.ChirpMedium{}, err
+	}
+	count, err := q.CountChirpMedia(ctx, arg.ChirpID)
+	if err != nil {
+		return database.ChirpMedium{}, err
+	}
+	if err := ent.CheckMedia(int(count)); err != nil {
+		return database.ChirpMedium{}, err
+	}
+	medium, err := q.CreateChirpMedia(ctx, arg)
+	if err != nil {
+		return database.ChirpMedium{}, err
+	}
+	return medium, tx.Commit()
+}
+
+func (cfg *apiConfig) mediaJSON(medium database.ChirpMedium) ChirpMedia {
+	return ChirpMedia{
+		ID:           medium.ID.String(),
+		URL:          cfg.media.URL(medium.StorageKey),
+		Content_type: medium.ContentType,
+	}
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/A-X-Z-Y-T-E/Chirpy/internal/auth"
+	"github.com/A-X-Z-Y-T-E/Chirpy/internal/database"
+	"github.com/A-X-Z-Y-T-E/Chirpy/internal/pagination"
+	"github.com/google/uuid"
+)
+
+type ChirpMention struct {
+	User_id string `json:"user_id"`
+	Handle  string `json:"handle"`
+}
+
+// ListMyMentions lists chirps that mention the authenticated user, newest
+// first.
+func (cfg *apiConfig) ListMyMentions() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		user, _ := auth.UserFromContext(r.Context())
+		UserID := user.ID
+		page, err := pagination.FromQuery(r.URL.Query())
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(struct {
+				Error string `json:"error"`
+			}{
+				Error: err.Error(),
+			})
+			return
+		}
+
+		chirps, err := cfg.db.ListMentions(r.Context(), database.ListMentionsParams{
+			UserID:         UserID,
+			AfterCreatedAt: page.AfterCreatedAt,
+			AfterID:        page.AfterID,
+			PageLimit:      page.Limit + 1,
+		})
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(struct {
+				Error string `json:"error"`
+			}{
+				Error: "Internal Server Error.",
+			})
+			return
+		}
+
+		out := chirpPage{}
+		if len(chirps) > int(page.Limit) {
+			chirps = chirps[:page.Limit]
+			last := chirps[len(chirps)-1]
+			out.NextCursor = pagination.EncodeCursor(last.CreatedAt, last.ID)
+		}
+		out.Chirps, err = cfg.chirpsJSON(r.Context(), uuid.NullUUID{UUID: UserID, Valid: true}, chirps)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(struct {
+				Error string `json:"error"`
+			}{
+				Error: "Internal Server Error.",
+			})
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(out)
+	})
+}
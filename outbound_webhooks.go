@@ -0,0 +1,482 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/A-X-Z-Y-T-E/Chirpy/internal/database"
+	"github.com/A-X-Z-Y-T-E/Chirpy/internal/jobs"
+	"github.com/A-X-Z-Y-T-E/Chirpy/internal/pagination"
+	"github.com/A-X-Z-Y-T-E/Chirpy/internal/webhook"
+	"github.com/google/uuid"
+)
+
+// Events other services can subscribe to.
+const (
+	eventChirpCreated = "chirp.created"
+	eventChirpDeleted = "chirp.deleted"
+	eventUserCreated  = "user.created"
+	eventUserUpgraded = "user.upgraded"
+)
+
+var outboundEventTypes = map[string]bool{
+	eventChirpCreated: true,
+	eventChirpDeleted: true,
+	eventUserCreated:  true,
+	eventUserUpgraded: true,
+}
+
+const (
+	deliveryPollInterval = 30 * time.Second
+	deliveryBatchSize    = 20
+	// deliveryLease has to cover a whole batch of sends at
+	// webhook.DefaultSendTimeout each.
+	deliveryLease       = 5 * time.Minute
+	deliveryMaxAttempts = 10
+)
+
+var deliveryRetry = webhook.RetryPolicy{
+	MaxAttempts: deliveryMaxAttempts,
+	Backoff:     jobs.Backoff{Base: 30 * time.Second, Max: 6 * time.Hour},
+}
+
+var deliveryStatuses = map[string]bool{
+	"pending":    true,
+	"delivering": true,
+	"delivered":  true,
+	"dead":       true,
+}
+
+// outboundEvent is the body of every delivery.
+type outboundEvent struct {
+	ID        string `json:"id"`
+	Event     string `json:"event"`
+	CreatedAt string `json:"created_at"`
+	Data      any    `json:"data"`
+}
+
+type WebhookSubscription struct {
+	ID         string   `json:"id"`
+	URL        string   `json:"url"`
+	EventTypes []string `json:"event_types"`
+	// Secret is only shown when the subscription is created.
+	Secret    string `json:"secret,omitempty"`
+	CreatedAt string `json:"created_at"`
+}
+
+type WebhookDelivery struct {
+	ID             string `json:"id"`
+	SubscriptionID string `json:"subscription_id"`
+	EventID        string `json:"event_id"`
+	EventType      string `json:"event_type"`
+	Status         string `json:"status"`
+	Attempts       int32  `json:"attempts"`
+	LastStatusCode int32  `json:"last_status_code,omitempty"`
+	LastError      string `json:"last_error,omitempty"`
+	CreatedAt      string `json:"created_at"`
+	NextAttemptAt  string `json:"next_attempt_at,omitempty"`
+	DeliveredAt    string `json:"delivered_at,omitempty"`
+}
+
+type webhookDeliveryPage struct {
+	Deliveries []WebhookDelivery `json:"deliveries"`
+	NextCursor string            `json:"next_cursor,omitempty"`
+}
+
+func webhookSubscriptionJSON(sub database.WebhookSubscription) WebhookSubscription {
+	return WebhookSubscription{
+		ID:         sub.ID.String(),
+		URL:        sub.Url,
+		EventTypes: sub.EventTypes,
+		CreatedAt:  sub.CreatedAt.String(),
+	}
+}
+
+func webhookDeliveryJSON(delivery database.WebhookDelivery) WebhookDelivery {
+	out := WebhookDelivery{
+		ID:             delivery.ID.String(),
+		SubscriptionID: delivery.SubscriptionID.String(),
+		EventID:        delivery.EventID.String(),
+		EventType:      delivery.EventType,
+		Status:         delivery.Status,
+		Attempts:       delivery.Attempts,
+		LastStatusCode: delivery.LastStatusCode.Int32,
+		LastError:      delivery.LastError.String,
+		CreatedAt:      delivery.CreatedAt.String(),
+	}
+	if delivery.Status == "pending" {
+		out.NextAttemptAt = delivery.NextAttemptAt.String()
+	}
+	if delivery.DeliveredAt.Valid {
+		out.DeliveredAt = delivery.DeliveredAt.Time.String()
+	}
+	return out
+}
+
+// emitEvent queues eventType for every subscriber to it. Failures are
+// logged rather than returned because the change the event describes has
+// already been made.
+func (cfg *apiConfig) emitEvent(ctx context.Context, eventType string, data any) {
+	id := uuid.New()
+	payload, err := json.Marshal(outboundEvent{
+		ID:        id.String(),
+		Event:     eventType,
+		CreatedAt: time.Now().UTC().Format(time.RFC3339Nano),
+		Data:      data,
+	})
+	if err != nil {
+		fmt.Println("emitEvent error:", err)
+		return
+	}
+	n, err := cfg.db.EnqueueWebhookDeliveries(ctx, database.EnqueueWebhookDeliveriesParams{
+		EventID:   id,
+		EventType: eventType,
+		Payload:   string(payload),
+	})
+	if err != nil {
+		fmt.Println("EnqueueWebhookDeliveries error:", err)
+		return
+	}
+	if n > 0 {
+		cfg.deliveryTrigger.Fire()
+	}
+}
+
+// deliverWebhooks sends every delivery that is due. It runs in the
+// background every deliveryPollInterval and whenever an event is emitted.
+func (cfg *apiConfig) deliverWebhooks(ctx context.Context) error {
+	for {
+		deliveries, err := cfg.db.ClaimWebhookDeliveries(ctx, database.ClaimWebhookDeliveriesParams{
+			LeaseUntil: time.Now().Add(deliveryLease),
+			BatchSize:  deliveryBatchSize,
+		})
+		if err != nil {
+			return err
+		}
+		if len(deliveries) == 0 {
+			return nil
+		}
+		for _, delivery := range deliveries {
+			cfg.deliverWebhook(ctx, delivery)
+		}
+	}
+}
+
+func (cfg *apiConfig) deliverWebhook(ctx context.Context, delivery database.ClaimWebhookDeliveriesRow) {
+	status, err := cfg.webhookSender.Send(ctx, webhook.Delivery{
+		ID:     delivery.ID.String(),
+		Event:  delivery.EventType,
+		URL:    delivery.Url,
+		Secret: delivery.Secret,
+		Body:   []byte(delivery.Payload),
+	})
+	statusCode := sql.NullInt32{Int32: int32(status), Valid: status != 0}
+	outcome, nextAttemptAt := deliveryRetry.Next(int(delivery.Attempts), err, time.Now())
+	switch outcome {
+	case webhook.OutcomeDelivered:
+		err = cfg.db.MarkWebhookDeliveryDelivered(ctx, database.MarkWebhookDeliveryDeliveredParams{
+			ID:             delivery.ID,
+			LastStatusCode: statusCode,
+		})
+		if err != nil {
+			fmt.Println("MarkWebhookDeliveryDelivered error:", err)
+		}
+	case webhook.OutcomeDead:
+		fmt.Printf("webhook delivery %s to %s is dead: %v\n", delivery.ID, delivery.Url, err)
+		err = cfg.db.MarkWebhookDeliveryDead(ctx, database.MarkWebhookDeliveryDeadParams{
+			ID:             delivery.ID,
+			LastStatusCode: statusCode,
+			LastError:      sql.NullString{String: err.Error(), Valid: true},
+		})
+		if err != nil {
+			fmt.Println("MarkWebhookDeliveryDead error:", err)
+		}
+	case webhook.OutcomeRetry:
+		err = cfg.db.RetryWebhookDelivery(ctx, database.RetryWebhookDeliveryParams{
+			ID:             delivery.ID,
+			LastStatusCode: statusCode,
+			LastError:      sql.NullString{String: err.Error(), Valid: true},
+			NextAttemptAt:  nextAttemptAt,
+		})
+		if err != nil {
+			fmt.Println("RetryWebhookDelivery error:", err)
+		}
+	}
+}
+
+// CreateWebhookSubscription registers a URL for some event types. The
+// signing secret is generated here and returned only in this response.
+func (cfg *apiConfig) CreateWebhookSubscription() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		type parameters struct {
+			URL        string   `json:"url"`
+			EventTypes []string `json:"event_types"`
+		}
+		var params parameters
+		err := json.NewDecoder(r.Body).Decode(&params)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(struct {
+				Error string `json:"error"`
+			}{
+				Error: "Invalid request body",
+			})
+			return
+		}
+		target, err := url.Parse(params.URL)
+		if err != nil || (target.Scheme != "http" && target.Scheme != "https") || target.Host == "" {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(struct {
+				Error string `json:"error"`
+			}{
+				Error: "Invalid URL",
+			})
+			return
+		}
+		if len(params.EventTypes) == 0 {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(struct {
+				Error string `json:"error"`
+			}{
+				Error: "At least one event type is required",
+			})
+			return
+		}
+		for _, eventType := range params.EventTypes {
+			if !outboundEventTypes[eventType] {
+				w.WriteHeader(http.StatusBadRequest)
+				json.NewEncoder(w).Encode(struct {
+					Error string `json:"error"`
+				}{
+					Error: fmt.Sprintf("Unknown event type %q", eventType),
+				})
+				return
+			}
+		}
+
+		secret, err := webhook.NewSecret()
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(struct {
+				Error string `json:"error"`
+			}{
+				Error: "Something went wrong",
+			})
+			return
+		}
+		sub, err := cfg.db.CreateWebhookSubscription(r.Context(), database.CreateWebhookSubscriptionParams{
+			Url:        target.String(),
+			Secret:     secret,
+			EventTypes: params.EventTypes,
+		})
+		if err != nil {
+			fmt.Println("CreateWebhookSubscription error:", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(struct {
+				Error string `json:"error"`
+			}{
+				Error: "Something went wrong",
+			})
+			return
+		}
+		out := webhookSubscriptionJSON(sub)
+		out.Secret = sub.Secret
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(out)
+	})
+}
+
+func (cfg *apiConfig) ListWebhookSubscriptions() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		subs, err := cfg.db.ListWebhookSubscriptions(r.Context())
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(struct {
+				Error string `json:"error"`
+			}{
+				Error: "Internal Server Error.",
+			})
+			return
+		}
+		out := []WebhookSubscription{}
+		for _, sub := range subs {
+			out = append(out, webhookSubscriptionJSON(sub))
+		}
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(out)
+	})
+}
+
+// DeleteWebhookSubscription removes a subscriber along with its queued and
+// dead deliveries.
+func (cfg *apiConfig) DeleteWebhookSubscription() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		SubscriptionID, err := convert_to_uuid(r.PathValue("subscriptionID"))
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(struct {
+				Error string `json:"error"`
+			}{
+				Error: "Invalid subscription ID",
+			})
+			return
+		}
+		n, err := cfg.db.DeleteWebhookSubscription(r.Context(), SubscriptionID)
+		if err != nil {
+			fmt.Println("DeleteWebhookSubscription error:", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(struct {
+				Error string `json:"error"`
+			}{
+				Error: "Something went wrong",
+			})
+			return
+		}
+		if n == 0 {
+			w.WriteHeader(http.StatusNotFound)
+			json.NewEncoder(w).Encode(struct {
+				Error string `json:"error"`
+			}{
+				Error: "Webhook subscription not found",
+			})
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
+
+// ListWebhookDeliveries lists a subscriber's deliveries, newest first;
+// ?status=dead shows the dead-letter queue.
+func (cfg *apiConfig) ListWebhookDeliveries() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		SubscriptionID, err := convert_to_uuid(r.PathValue("subscriptionID"))
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(struct {
+				Error string `json:"error"`
+			}{
+				Error: "Invalid subscription ID",
+			})
+			return
+		}
+		page, err := pagination.FromQuery(r.URL.Query())
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(struct {
+				Error string `json:"error"`
+			}{
+				Error: err.Error(),
+			})
+			return
+		}
+		status := r.URL.Query().Get("status")
+		if status != "" && !deliveryStatuses[status] {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(struct {
+				Error string `json:"error"`
+			}{
+				Error: "Invalid status",
+			})
+			return
+		}
+		if _, err := cfg.db.GetWebhookSubscription(r.Context(), SubscriptionID); err != nil {
+			w.WriteHeader(http.StatusNotFound)
+			json.NewEncoder(w).Encode(struct {
+				Error string `json:"error"`
+			}{
+				Error: "Webhook subscription not found",
+			})
+			return
+		}
+
+		deliveries, err := cfg.db.ListWebhookDeliveries(r.Context(), database.ListWebhookDeliveriesParams{
+			SubscriptionID: SubscriptionID,
+			Status:         sql.NullString{String: status, Valid: status != ""},
+			AfterCreatedAt: page.AfterCreatedAt,
+			AfterID:        page.AfterID,
+			PageLimit:      page.Limit + 1,
+		})
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(struct {
+				Error string `json:"error"`
+			}{
+				Error: "Internal Server Error.",
+			})
+			return
+		}
+
+		out := webhookDeliveryPage{Deliveries: []WebhookDelivery{}}
+		if len(deliveries) > int(page.Limit) {
+			deliveries = deliveries[:page.Limit]
+			last := deliveries[len(deliveries)-1]
+			out.NextCursor = pagination.EncodeCursor(last.CreatedAt, last.ID)
+		}
+		for _, delivery := range deliveries {
+			out.Deliveries = append(out.Deliveries, webhookDeliveryJSON(delivery))
+		}
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(out)
+	})
+}
+
+// RedeliverWebhookDelivery takes a delivery out of the dead-letter state
+// and queues it again with a fresh set of attempts.
+func (cfg *apiConfig) RedeliverWebhookDelivery() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		DeliveryID, err := convert_to_uuid(r.PathValue("deliveryID"))
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(struct {
+				Error string `json:"error"`
+			}{
+				Error: "Invalid delivery ID",
+			})
+			return
+		}
+		delivery, err := cfg.db.RedeliverWebhookDelivery(r.Context(), DeliveryID)
+		if errors.Is(err, sql.ErrNoRows) {
+			// Either there is no such delivery or it is not dead.
+			status, msg := http.StatusConflict, "Only dead deliveries can be redelivered"
+			if _, err := cfg.db.GetWebhookDelivery(r.Context(), DeliveryID); err != nil {
+				status, msg = http.StatusNotFound, "Webhook delivery not found"
+			}
+			w.WriteHeader(status)
+			json.NewEncoder(w).Encode(struct {
+				Error string `json:"error"`
+			}{
+				Error: msg,
+			})
+			return
+		}
+		if err != nil {
+			fmt.Println("RedeliverWebhookDelivery error:", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(struct {
+				Error string `json:"error"`
+			}{
+				Error: "Something went wrong",
+			})
+			return
+		}
+		cfg.deliveryTrigger.Fire()
+		w.WriteHeader(http.StatusAccepted)
+		json.NewEncoder(w).Encode(webhookDeliveryJSON(delivery))
+	})
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/A-X-Z-Y-T-E/Chirpy/internal/auth"
+	"github.com/A-X-Z-Y-T-E/Chirpy/internal/database"
+	"github.com/A-X-Z-Y-T-E/Chirpy/internal/lockout"
+	"github.com/A-X-Z-Y-T-E/Chirpy/internal/mailer"
+)
+
+const passwordResetTTL = 1 * time.Hour
+
+// RequestPasswordReset emails a reset link. It answers 202 whether or not the
+// address belongs to an account, and does the lookup and the rest of the
+// work after answering, so neither the status nor the response time says
+// whether an account exists. Requests are throttled per address and per
+// client in the same way, so a throttled request also gets a 202.
+func (cfg *apiConfig) RequestPasswordReset() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		type parameters struct {
+			Email string `json:"email"`
+		}
+		var params parameters
+		err := json.NewDecoder(r.Body).Decode(&params)
+		if err != nil || params.Email == "" {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(struct {
+				Error string `json:"error"`
+			}{
+				Error: "Invalid request body",
+			})
+			return
+		}
+
+		go cfg.sendPasswordReset(params.Email,
+			throttle{key: "reset:" + emailThrottleKey(params.Email), policy: lockout.ResetEmail},
+			throttle{key: "reset:" + ipThrottleKey(r), policy: lockout.ResetIP, shared: true},
+		)
+		w.WriteHeader(http.StatusAccepted)
+	})
+}
+
+// sendPasswordReset creates a reset token for the account with email, if
+// there is one, and mails the link to it. Nothing is sent once any of
+// throttles is locked.
+func (cfg *apiConfig) sendPasswordReset(email string, throttles ...throttle) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	_, err := cfg.recordLoginAttempt(ctx, throttles...)
+	if errors.Is(err, sql.ErrNoRows) {
+		return
+	}
+	if err != nil {
+		fmt.Println("recordLoginAttempt error:", err)
+	}
+	user, err := cfg.db.GetUser(ctx, email)
+	if err != nil {
+		return
+	}
+	token, tokenHash, err := auth.MakeSingleUseToken()
+	if err != nil {
+		fmt.Println("MakeSingleUseToken error:", err)
+		return
+	}
+	err = cfg.db.CreatePasswordReset(ctx, database.CreatePasswordResetParams{
+		TokenHash: tokenHash,
+		UserID:    user.ID,
+		ExpiresAt: time.Now().Add(passwordResetTTL),
+	})
+	if err != nil {
+		fmt.Println("CreatePasswordReset error:", err)
+		return
+	}
+
+	err = cfg.mailer.Send(ctx, mailer.Message{
+		To:      user.Email,
+		Subject: "Reset your Chirpy password",
+		Body: fmt.Sprintf("Someone asked to reset the password for your Chirpy account.\n\n"+
+			"Follow this link within %v to choose a new one:\n%s/app/reset-password?token=%s\n\n"+
+			"If it wasn't you, you can ignore this email.\n",
+			passwordResetTTL, cfg.baseURL, url.QueryEscape(token)),
+	})
+	if err != nil {
+		fmt.Println("mail error:", err)
+	}
+}
+
+// ConfirmPasswordReset sets a new password. The token is used up, any other
+// outstanding reset tokens are voided, and every session is logged out.
+func (cfg *apiConfig) ConfirmPasswordReset() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		type parameters struct {
+			Token    string `json:"token"`
+			Password string `json:"password"`
+		}
+		var params parameters
+		err := json.NewDecoder(r.Body).Decode(&params)
+		if err != nil || params.Token == "" || params.Password == "" {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(struct {
+				Error string `json:"error"`
+			}{
+				Error: "Invalid request body",
+			})
+			return
+		}
+
+		UserID, err := cfg.db.ConsumePasswordReset(r.Context(), auth.HashToken(params.Token))
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(struct {
+				Error string `json:"error"`
+			}{
+				Error: "Invalid or expired token",
+			})
+			return
+		}
+		hashed_password, err := auth.HashPassword(params.Password)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(struct {
+				Error string `json:"error"`
+			}{
+				Error: "Something went wrong",
+			})
+			return
+		}
+		err = cfg.db.SetUserPassword(r.Context(), database.SetUserPasswordParams{
+			ID:             UserID,
+			HashedPassword: hashed_password,
+		})
+		if err != nil {
+			fmt.Println("SetUserPassword error:", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(struct {
+				Error string `json:"error"`
+			}{
+				Error: "Something went wrong",
+			})
+			return
+		}
+		if err := cfg.db.InvalidatePasswordResets(r.Context(), UserID); err != nil {
+			fmt.Println("InvalidatePasswordResets error:", err)
+		}
+		if err := cfg.db.RevokeAllUserSessions(r.Context(), UserID); err != nil {
+			fmt.Println("RevokeAllUserSessions error:", err)
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
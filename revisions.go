@@ -0,0 +1,160 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/A-X-Z-Y-T-E/Chirpy/internal/auth"
+	"github.com/A-X-Z-Y-T-E/Chirpy/internal/database"
+	"github.com/A-X-Z-Y-T-E/Chirpy/internal/entitlements"
+	"github.com/google/uuid"
+)
+
+type ChirpRevision struct {
+	ID         string `json:"id"`
+	Body       string `json:"body"`
+	CreatedAt  string `json:"created_at"`
+	ReplacedAt string `json:"replaced_at"`
+}
+
+func (cfg *apiConfig) UpdateChirp() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		user, _ := auth.UserFromContext(r.Context())
+		UserID := user.ID
+
+		ChirpID, err := convert_to_uuid(r.PathValue("chirpID"))
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(struct {
+				Error string `json:"error"`
+			}{
+				Error: "Invalid chirp ID",
+			})
+			return
+		}
+		chirp, err := cfg.db.GetChirpByID(r.Context(), ChirpID)
+		if err != nil {
+			w.WriteHeader(http.StatusNotFound)
+			json.NewEncoder(w).Encode(struct {
+				Error string `json:"error"`
+			}{
+				Error: "Chirp not found",
+			})
+			return
+		}
+		if chirp.UserID != UserID {
+			w.WriteHeader(http.StatusForbidden)
+			json.NewEncoder(w).Encode(struct {
+				Error string `json:"error"`
+			}{
+				Error: "Forbidden",
+			})
+			return
+		}
+		ent := entitlements.For(user.IsChirpyRed)
+		if err := ent.CheckEdit(chirp.CreatedAt, time.Now().UTC()); err != nil {
+			writeEntitlementError(w, err)
+			return
+		}
+
+		type parameters struct {
+			Body string `json:"body"`
+		}
+		var params parameters
+		err = json.NewDecoder(r.Body).Decode(&params)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(struct {
+				Error string `json:"error"`
+			}{
+				Error: "Invalid request body",
+			})
+			return
+		}
+		body, ok := cfg.checkChirpBody(w, params.Body, ent)
+		if !ok {
+			return
+		}
+
+		updated, err := cfg.db.UpdateChirpBody(r.Context(), database.UpdateChirpBodyParams{
+			ID:   ChirpID,
+			Body: body,
+		})
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(struct {
+				Error string `json:"error"`
+			}{
+				Error: "Something went wrong",
+			})
+			return
+		}
+		cfg.indexChirp(r.Context(), updated)
+
+		out, err := cfg.chirpsJSON(r.Context(), uuid.NullUUID{UUID: UserID, Valid: true}, []database.Chirp{updated})
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(struct {
+				Error string `json:"error"`
+			}{
+				Error: "Something went wrong",
+			})
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(out[0])
+	})
+}
+
+// ListRevisions returns the previous bodies of a chirp, most recent first.
+func (cfg *apiConfig) ListRevisions() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		ChirpID, err := convert_to_uuid(r.PathValue("chirpID"))
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(struct {
+				Error string `json:"error"`
+			}{
+				Error: "Invalid chirp ID",
+			})
+			return
+		}
+		if _, err := cfg.db.GetChirpByID(r.Context(), ChirpID); err != nil {
+			w.WriteHeader(http.StatusNotFound)
+			json.NewEncoder(w).Encode(struct {
+				Error string `json:"error"`
+			}{
+				Error: "Chirp not found",
+			})
+			return
+		}
+
+		revisions, err := cfg.db.ListChirpRevisions(r.Context(), ChirpID)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(struct {
+				Error string `json:"error"`
+			}{
+				Error: "Internal Server Error.",
+			})
+			return
+		}
+
+		out := []ChirpRevision{}
+		for _, revision := range revisions {
+			out = append(out, ChirpRevision{
+				ID:         revision.ID.String(),
+				Body:       revision.Body,
+				CreatedAt:  revision.CreatedAt.String(),
+				ReplacedAt: revision.ReplacedAt.String(),
+			})
+		}
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(out)
+	})
+}
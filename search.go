@@ -0,0 +1,120 @@
+package main
+
+import (
+	"encoding/json"
+	"html"
+	"net/http"
+	"strings"
+
+	"github.com/A-X-Z-Y-T-E/Chirpy/internal/database"
+	"github.com/A-X-Z-Y-T-E/Chirpy/internal/pagination"
+)
+
+// ts_headline does not escape the chirp body, so matches are wrapped in
+// control characters first and only turned into <mark> after escaping.
+const (
+	highlightStart    = "\x02"
+	highlightStop     = "\x03"
+	headlineOptions   = "StartSel=\x02, StopSel=\x03, MaxWords=35, MinWords=15"
+	maxSearchQueryLen = 256
+)
+
+type SearchResult struct {
+	Chirp
+	Rank    float32 `json:"rank"`
+	Snippet string  `json:"snippet"`
+}
+
+type searchPage struct {
+	Results    []SearchResult `json:"results"`
+	NextCursor string         `json:"next_cursor,omitempty"`
+}
+
+func highlightSnippet(snippet string) string {
+	snippet = html.EscapeString(snippet)
+	snippet = strings.ReplaceAll(snippet, highlightStart, "<mark>")
+	return strings.ReplaceAll(snippet, highlightStop, "</mark>")
+}
+
+func (cfg *apiConfig) SearchChirps() http.Handler {
+	return http.HandlerFunc(func(resW http.ResponseWriter, req *http.Request) {
+		resW.Header().Set("Content-Type", "application/json")
+
+		q := strings.TrimSpace(req.URL.Query().Get("q"))
+		if q == "" || len(q) > maxSearchQueryLen {
+			resW.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(resW).Encode(struct {
+				Error string `json:"error"`
+			}{
+				Error: "Invalid search query",
+			})
+			return
+		}
+		author, err := parseAuthorID(req.URL.Query().Get("author_id"))
+		if err != nil {
+			resW.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(resW).Encode(struct {
+				Error string `json:"error"`
+			}{
+				Error: "Invalid author_id",
+			})
+			return
+		}
+		limit, offset, err := pagination.OffsetFromQuery(req.URL.Query())
+		if err != nil {
+			resW.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(resW).Encode(struct {
+				Error string `json:"error"`
+			}{
+				Error: err.Error(),
+			})
+			return
+		}
+
+		rows, err := cfg.db.SearchChirps(req.Context(), database.SearchChirpsParams{
+			HeadlineOptions: headlineOptions,
+			Query:           q,
+			AuthorID:        author,
+			PageLimit:       limit + 1,
+			PageOffset:      offset,
+		})
+		if err != nil {
+			resW.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(resW).Encode(struct {
+				Error string `json:"error"`
+			}{
+				Error: "Internal Server Error.",
+			})
+			return
+		}
+
+		out := searchPage{Results: []SearchResult{}}
+		if len(rows) > int(limit) {
+			rows = rows[:limit]
+			out.NextCursor = pagination.EncodeOffsetCursor(offset + limit)
+		}
+		chirps := make([]database.Chirp, 0, len(rows))
+		for _, row := range rows {
+			chirps = append(chirps, row.Chirp)
+		}
+		converted, err := cfg.chirpsJSON(req.Context(), cfg.viewerID(req), chirps)
+		if err != nil {
+			resW.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(resW).Encode(struct {
+				Error string `json:"error"`
+			}{
+				Error: "Internal Server Error.",
+			})
+			return
+		}
+		for i, row := range rows {
+			out.Results = append(out.Results, SearchResult{
+				Chirp:   converted[i],
+				Rank:    row.Rank,
+				Snippet: highlightSnippet(row.Snippet),
+			})
+		}
+		resW.WriteHeader(http.StatusOK)
+		json.NewEncoder(resW).Encode(out)
+	})
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+
+	"github.com/A-X-Z-Y-T-E/Chirpy/internal/auth"
+	"github.com/A-X-Z-Y-T-E/Chirpy/internal/database"
+)
+
+// A session is one refresh token family: it starts at login and survives
+// every rotation until it is revoked or expires.
+type Session struct {
+	ID         string `json:"id"`
+	StartedAt  string `json:"started_at"`
+	LastUsedAt string `json:"last_used_at"`
+	ExpiresAt  string `json:"expires_at"`
+	UserAgent  string `json:"user_agent"`
+	IpAddress  string `json:"ip_address"`
+}
+
+// clientIP is the address of the peer that made the request. Proxy headers
+// are ignored since the server is not configured behind a trusted proxy.
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+func (cfg *apiConfig) ListSessions() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		user, _ := auth.UserFromContext(r.Context())
+		UserID := user.ID
+
+		rows, err := cfg.db.ListActiveSessions(r.Context(), UserID)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(struct {
+				Error string `json:"error"`
+			}{
+				Error: "Internal Server Error.",
+			})
+			return
+		}
+
+		sessions := []Session{}
+		for _, row := range rows {
+			sessions = append(sessions, Session{
+				ID:         row.FamilyID.String(),
+				StartedAt:  row.StartedAt.String(),
+				LastUsedAt: row.LastUsedAt.String(),
+				ExpiresAt:  row.ExpiresAt.String(),
+				UserAgent:  row.UserAgent,
+				IpAddress:  row.IpAddress,
+			})
+		}
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(sessions)
+	})
+}
+
+func (cfg *apiConfig) RevokeSession() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		user, _ := auth.UserFromContext(r.Context())
+		UserID := user.ID
+
+		SessionID, err := convert_to_uuid(r.PathValue("id"))
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(struct {
+				Error string `json:"error"`
+			}{
+				Error: "Invalid session ID",
+			})
+			return
+		}
+
+		revoked, err := cfg.db.RevokeUserSession(r.Context(), database.RevokeUserSessionParams{
+			FamilyID: SessionID,
+			UserID:   UserID,
+		})
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(struct {
+				Error string `json:"error"`
+			}{
+				Error: "Something went wrong",
+			})
+			return
+		}
+		if revoked == 0 {
+			w.WriteHeader(http.StatusNotFound)
+			json.NewEncoder(w).Encode(struct {
+				Error string `json:"error"`
+			}{
+				Error: "Session not found",
+			})
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
+
+// RevokeAllSessions logs the user out everywhere. Access tokens already
+// issued stay valid until they expire.
+func (cfg *apiConfig) RevokeAllSessions() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		user, _ := auth.UserFromContext(r.Context())
+		UserID := user.ID
+
+		err := cfg.db.RevokeAllUserSessions(r.Context(), UserID)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(struct {
+				Error string `json:"error"`
+			}{
+				Error: "Something went wrong",
+			})
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
@@ -0,0 +1,18 @@
+-- name: CreateChirpMedia :one
+INSERT INTO chirp_media (chirp_id, storage_key, content_type, size_bytes)
+VALUES (
+    $1,
+    $2,
+    $3,
+    $4
+)
+RETURNING *;
+
+-- name: CountChirpMedia :one
+SELECT COUNT(*) FROM chirp_media
+WHERE chirp_id = $1;
+
+-- name: GetChirpMedia :many
+SELECT * FROM chirp_media
+WHERE chirp_id = ANY(sqlc.arg('chirp_ids')::uuid[])
+ORDER BY created_at ASC;
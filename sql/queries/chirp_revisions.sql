@@ -0,0 +1,16 @@
+-- name: UpdateChirpBody :one
+WITH previous AS (
+    INSERT INTO chirp_revisions (chirp_id, body, created_at)
+    SELECT chirps.id, chirps.body, chirps.updated_at FROM chirps
+    WHERE chirps.id = sqlc.arg('id')
+    RETURNING chirp_id
+)
+UPDATE chirps
+SET body = sqlc.arg('body'), updated_at = NOW()
+WHERE chirps.id = (SELECT chirp_id FROM previous)
+RETURNING *;
+
+-- name: ListChirpRevisions :many
+SELECT * FROM chirp_revisions
+WHERE chirp_id = $1
+ORDER BY replaced_at DESC;
@@ -1,18 +1,16 @@
 -- name: CreateChirp :one
-INSERT INTO chirps (id, created_at, updated_at,body,user_id)
+INSERT INTO chirps (id, created_at, updated_at,body,user_id,in_reply_to,reply_path)
 VALUES (
     gen_random_uuid(),
     CURRENT_TIMESTAMP,
     CURRENT_TIMESTAMP,
     $1,
-    $2
+    $2,
+    $3,
+    $4
 )
 RETURNING *;
 
--- name: ReturnChirps :many
-SELECT * FROM chirps
-ORDER BY created_at;
-
 -- name: GetChirpByID :one
 SELECT * FROM chirps
 WHERE id = $1;
@@ -21,7 +19,49 @@ WHERE id = $1;
 DELETE FROM chirps
 WHERE id = $1;
 
--- name: GetChirpByUserID :many
+-- name: ListChirpsAsc :many
+SELECT * FROM chirps
+WHERE (sqlc.narg('author_id')::uuid IS NULL OR user_id = sqlc.narg('author_id')::uuid)
+AND (
+    sqlc.narg('after_created_at')::timestamp IS NULL
+    OR (created_at, id) > (sqlc.narg('after_created_at')::timestamp, sqlc.narg('after_id')::uuid)
+)
+ORDER BY created_at ASC, id ASC
+LIMIT sqlc.arg('page_limit');
+
+-- name: ListChirpsDesc :many
+SELECT * FROM chirps
+WHERE (sqlc.narg('author_id')::uuid IS NULL OR user_id = sqlc.narg('author_id')::uuid)
+AND (
+    sqlc.narg('after_created_at')::timestamp IS NULL
+    OR (created_at, id) < (sqlc.narg('after_created_at')::timestamp, sqlc.narg('after_id')::uuid)
+)
+ORDER BY created_at DESC, id DESC
+LIMIT sqlc.arg('page_limit');
+
+
+-- name: GetChirpsByIDs :many
+SELECT * FROM chirps
+WHERE id = ANY(sqlc.arg('ids')::uuid[]);
+
+-- name: GetChirpDescendants :many
 SELECT * FROM chirps
-WHERE user_id = $1
-ORDER BY created_at;
\ No newline at end of file
+WHERE reply_path @> ARRAY[sqlc.arg('chirp_id')::uuid]
+ORDER BY created_at ASC, id ASC
+LIMIT sqlc.arg('page_limit');
+
+-- name: CountReplies :many
+SELECT in_reply_to::uuid AS chirp_id, COUNT(*) AS reply_count
+FROM chirps
+WHERE in_reply_to = ANY(sqlc.arg('ids')::uuid[])
+GROUP BY in_reply_to;
+
+-- name: CountRecentChirps :one
+SELECT COUNT(*) AS count, COALESCE(MIN(created_at), NOW())::timestamp AS oldest
+FROM chirps
+WHERE user_id = $1 AND created_at > $2;
+
+-- name: LockChirp :one
+SELECT id FROM chirps
+WHERE id = $1
+FOR UPDATE;
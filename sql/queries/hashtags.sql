@@ -0,0 +1,38 @@
+-- name: AddChirpHashtags :exec
+WITH tags AS (
+    INSERT INTO hashtags (tag)
+    SELECT unnest(sqlc.arg('tags')::text[])
+    ON CONFLICT (tag) DO UPDATE SET tag = EXCLUDED.tag
+    RETURNING id
+)
+INSERT INTO chirp_hashtags (chirp_id, hashtag_id)
+SELECT sqlc.arg('chirp_id'), tags.id FROM tags
+ON CONFLICT DO NOTHING;
+
+-- name: ListChirpsByHashtag :many
+SELECT chirps.* FROM chirps
+JOIN chirp_hashtags ON chirp_hashtags.chirp_id = chirps.id
+JOIN hashtags ON hashtags.id = chirp_hashtags.hashtag_id
+WHERE hashtags.tag = sqlc.arg('tag')
+AND (
+    sqlc.narg('after_created_at')::timestamp IS NULL
+    OR (chirps.created_at, chirps.id) < (sqlc.narg('after_created_at')::timestamp, sqlc.narg('after_id')::uuid)
+)
+ORDER BY chirps.created_at DESC, chirps.id DESC
+LIMIT sqlc.arg('page_limit');
+
+-- name: TrendingHashtags :many
+SELECT hashtags.tag, COUNT(*) AS uses
+FROM chirp_hashtags
+JOIN hashtags ON hashtags.id = chirp_hashtags.hashtag_id
+WHERE chirp_hashtags.created_at > sqlc.arg('since')
+GROUP BY hashtags.tag
+ORDER BY uses DESC, hashtags.tag ASC
+LIMIT sqlc.arg('page_limit');
+
+-- name: RemoveStaleChirpHashtags :exec
+DELETE FROM chirp_hashtags
+USING hashtags
+WHERE hashtags.id = chirp_hashtags.hashtag_id
+AND chirp_hashtags.chirp_id = sqlc.arg('chirp_id')
+AND NOT (hashtags.tag = ANY(sqlc.arg('tags')::text[]));
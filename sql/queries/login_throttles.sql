@@ -0,0 +1,57 @@
+-- name: ClearLoginThrottle :exec
+DELETE FROM login_throttles
+WHERE throttle_key = $1;
+
+-- name: CreateLockoutEvent :exec
+INSERT INTO lockout_events (user_id, throttle_key, ip_address, failures, locked_until)
+VALUES (
+    $1,
+    $2,
+    $3,
+    $4,
+    $5
+);
+
+-- name: ForgiveLoginAttempt :exec
+UPDATE login_throttles
+SET failures = GREATEST(failures - 1, 0),
+    locked_until = CASE
+        WHEN failures - 1 < sqlc.arg('threshold') THEN NULL
+        ELSE locked_until
+    END
+WHERE throttle_key = sqlc.arg('throttle_key');
+
+-- name: GetActiveLockouts :many
+SELECT * FROM login_throttles
+WHERE throttle_key = ANY(sqlc.arg('throttle_keys')::text[]) AND locked_until > NOW();
+
+-- name: ListLockoutEvents :many
+SELECT * FROM lockout_events
+ORDER BY created_at DESC
+LIMIT $1;
+
+-- name: LockLoginThrottle :exec
+UPDATE login_throttles
+SET locked_until = $2
+WHERE throttle_key = $1;
+
+-- name: RecordLoginAttempt :one
+INSERT INTO login_throttles (throttle_key, failures, last_failure_at)
+VALUES (
+    sqlc.arg('throttle_key'),
+    1,
+    NOW()
+)
+ON CONFLICT (throttle_key) DO UPDATE
+SET failures = CASE
+        WHEN login_throttles.last_failure_at < sqlc.arg('window_start') THEN 1
+        ELSE login_throttles.failures + 1
+    END,
+    last_failure_at = NOW()
+WHERE login_throttles.locked_until IS NULL OR login_throttles.locked_until <= NOW()
+RETURNING failures;
+
+-- name: UnlockUserLockouts :exec
+UPDATE lockout_events
+SET unlocked_at = NOW()
+WHERE user_id = $1 AND unlocked_at IS NULL;
@@ -0,0 +1,27 @@
+-- name: AddChirpMentions :exec
+INSERT INTO mentions (chirp_id, user_id)
+SELECT sqlc.arg('chirp_id'), users.id FROM users
+WHERE users.handle = ANY(sqlc.arg('handles')::text[])
+ON CONFLICT DO NOTHING;
+
+-- name: GetChirpMentions :many
+SELECT mentions.chirp_id, users.id AS user_id, users.handle::text AS handle
+FROM mentions
+JOIN users ON users.id = mentions.user_id
+WHERE mentions.chirp_id = ANY(sqlc.arg('chirp_ids')::uuid[])
+AND users.handle IS NOT NULL;
+
+-- name: ListMentions :many
+SELECT chirps.* FROM chirps
+JOIN mentions ON mentions.chirp_id = chirps.id
+WHERE mentions.user_id = sqlc.arg('user_id')
+AND (
+    sqlc.narg('after_created_at')::timestamp IS NULL
+    OR (chirps.created_at, chirps.id) < (sqlc.narg('after_created_at')::timestamp, sqlc.narg('after_id')::uuid)
+)
+ORDER BY chirps.created_at DESC, chirps.id DESC
+LIMIT sqlc.arg('page_limit');
+
+-- name: ClearChirpMentions :exec
+DELETE FROM mentions
+WHERE chirp_id = $1;
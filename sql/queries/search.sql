@@ -0,0 +1,10 @@
+-- name: SearchChirps :many
+SELECT sqlc.embed(chirps),
+    ts_rank(to_tsvector('english', chirps.body), query)::real AS rank,
+    ts_headline('english', chirps.body, query, sqlc.arg('headline_options'))::text AS snippet
+FROM chirps, websearch_to_tsquery('english', sqlc.arg('query')) query
+WHERE to_tsvector('english', chirps.body) @@ query
+AND (sqlc.narg('author_id')::uuid IS NULL OR chirps.user_id = sqlc.narg('author_id')::uuid)
+ORDER BY rank DESC, chirps.created_at DESC, chirps.id DESC
+LIMIT sqlc.arg('page_limit')
+OFFSET sqlc.arg('page_offset');
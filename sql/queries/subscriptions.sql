@@ -0,0 +1,53 @@
+-- name: CancelSubscription :execrows
+UPDATE subscriptions
+SET status = 'canceled', cancel_at_period_end = true, current_period_end = COALESCE(current_period_end, NOW()), last_event_at = sqlc.arg('event_at')::timestamp, updated_at = NOW()
+WHERE user_id = sqlc.arg('user_id') AND status <> 'expired'
+    AND (last_event_at IS NULL OR last_event_at <= sqlc.arg('event_at')::timestamp);
+
+-- name: EndSubscription :execrows
+UPDATE subscriptions
+SET status = 'expired', current_period_end = LEAST(current_period_end, NOW()), last_event_at = sqlc.arg('event_at')::timestamp, updated_at = NOW()
+WHERE user_id = sqlc.arg('user_id')
+    AND (last_event_at IS NULL OR last_event_at <= sqlc.arg('event_at')::timestamp);
+
+-- name: ExpireSubscriptions :many
+WITH expired AS (
+    UPDATE subscriptions
+    SET status = 'expired', updated_at = NOW()
+    WHERE status <> 'expired' AND current_period_end < NOW()
+    RETURNING user_id
+)
+UPDATE users
+SET is_chirpy_red = false, updated_at = NOW()
+FROM expired
+WHERE users.id = expired.user_id
+RETURNING users.id;
+
+-- name: GetSubscription :one
+SELECT * FROM subscriptions
+WHERE user_id = $1;
+
+-- name: MarkSubscriptionPastDue :execrows
+UPDATE subscriptions
+SET status = 'past_due', last_event_at = sqlc.arg('event_at')::timestamp, updated_at = NOW()
+WHERE user_id = sqlc.arg('user_id') AND status <> 'expired'
+    AND (last_event_at IS NULL OR last_event_at <= sqlc.arg('event_at')::timestamp);
+
+-- name: UpsertSubscription :one
+INSERT INTO subscriptions (user_id, plan, status, current_period_end, last_event_at)
+VALUES (
+    sqlc.arg('user_id'),
+    sqlc.arg('plan'),
+    'active',
+    sqlc.arg('current_period_end'),
+    sqlc.arg('event_at')::timestamp
+)
+ON CONFLICT (user_id) DO UPDATE
+SET plan = EXCLUDED.plan,
+    status = 'active',
+    current_period_end = GREATEST(subscriptions.current_period_end, EXCLUDED.current_period_end),
+    cancel_at_period_end = false,
+    last_event_at = EXCLUDED.last_event_at,
+    updated_at = NOW()
+WHERE subscriptions.last_event_at IS NULL OR subscriptions.last_event_at <= EXCLUDED.last_event_at
+RETURNING *;
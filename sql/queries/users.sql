@@ -1,12 +1,13 @@
 -- name: CreateUser :one
-INSERT INTO users (id, created_at, updated_at, email,hashed_password)
+INSERT INTO users (id, created_at, updated_at, email,hashed_password,handle)
 VALUES (
     
     gen_random_uuid(),
     CURRENT_TIMESTAMP,
     CURRENT_TIMESTAMP,
     $1,
-    $2
+    $2,
+    $3
     
 )
 RETURNING *;
@@ -22,12 +23,47 @@ WHERE email = $1;
 SELECT * FROM users
 WHERE id = $1;
 
--- name: UpdateUser :exec
-UPDATE users
-SET email = $1, hashed_password = $2
-WHERE id = $3;
+-- name: LockUser :one
+SELECT id FROM users
+WHERE id = $1
+FOR UPDATE;
 
 -- name: UpgradeUserToChirpyRed :exec
 UPDATE users
 SET is_chirpy_red = true, updated_at = NOW()
-WHERE id = $1;
\ No newline at end of file
+WHERE id = $1;
+
+-- name: SetUserHandle :exec
+UPDATE users
+SET handle = $2, updated_at = NOW()
+WHERE id = $1;
+
+-- name: SetUserRole :one
+UPDATE users
+SET role = $2, updated_at = NOW()
+WHERE id = $1
+RETURNING *;
+
+
+-- name: SetUserPassword :exec
+UPDATE users
+SET hashed_password = $2, updated_at = NOW()
+WHERE id = $1;
+
+
+-- name: SetPendingEmail :exec
+UPDATE users
+SET pending_email = $2, updated_at = NOW()
+WHERE id = $1;
+
+-- name: VerifyUserEmail :one
+UPDATE users
+SET email = $2, email_verified_at = NOW(), pending_email = NULL, updated_at = NOW()
+WHERE id = $1 AND (pending_email = $2 OR (email = $2 AND email_verified_at IS NULL))
+RETURNING *;
+
+
+-- name: DowngradeUserFromChirpyRed :exec
+UPDATE users
+SET is_chirpy_red = false, updated_at = NOW()
+WHERE id = $1;
@@ -0,0 +1,58 @@
+-- name: ClaimWebhookDeliveries :many
+WITH claimed AS (
+    UPDATE webhook_deliveries
+    SET status = 'delivering', attempts = attempts + 1, next_attempt_at = sqlc.arg('lease_until')
+    WHERE id IN (
+        SELECT id FROM webhook_deliveries
+        WHERE status IN ('pending', 'delivering') AND next_attempt_at <= NOW()
+        ORDER BY next_attempt_at
+        LIMIT sqlc.arg('batch_size')
+        FOR UPDATE SKIP LOCKED
+    )
+    RETURNING *
+)
+SELECT claimed.*, webhook_subscriptions.url, webhook_subscriptions.secret
+FROM claimed
+JOIN webhook_subscriptions ON webhook_subscriptions.id = claimed.subscription_id;
+
+-- name: EnqueueWebhookDeliveries :execrows
+INSERT INTO webhook_deliveries (subscription_id, event_id, event_type, payload)
+SELECT id, sqlc.arg('event_id'), sqlc.arg('event_type')::text, sqlc.arg('payload')
+FROM webhook_subscriptions
+WHERE sqlc.arg('event_type')::text = ANY(event_types);
+
+-- name: GetWebhookDelivery :one
+SELECT * FROM webhook_deliveries
+WHERE id = $1;
+
+-- name: ListWebhookDeliveries :many
+SELECT * FROM webhook_deliveries
+WHERE subscription_id = sqlc.arg('subscription_id')
+AND (sqlc.narg('status')::text IS NULL OR status = sqlc.narg('status')::text)
+AND (
+    sqlc.narg('after_created_at')::timestamp IS NULL
+    OR (created_at, id) < (sqlc.narg('after_created_at')::timestamp, sqlc.narg('after_id')::uuid)
+)
+ORDER BY created_at DESC, id DESC
+LIMIT sqlc.arg('page_limit');
+
+-- name: MarkWebhookDeliveryDead :exec
+UPDATE webhook_deliveries
+SET status = 'dead', last_status_code = $2, last_error = $3
+WHERE id = $1;
+
+-- name: MarkWebhookDeliveryDelivered :exec
+UPDATE webhook_deliveries
+SET status = 'delivered', last_status_code = $2, last_error = NULL, delivered_at = NOW()
+WHERE id = $1;
+
+-- name: RedeliverWebhookDelivery :one
+UPDATE webhook_deliveries
+SET status = 'pending', attempts = 0, next_attempt_at = NOW()
+WHERE id = $1 AND status = 'dead'
+RETURNING *;
+
+-- name: RetryWebhookDelivery :exec
+UPDATE webhook_deliveries
+SET status = 'pending', last_status_code = $2, last_error = $3, next_attempt_at = $4
+WHERE id = $1;
@@ -0,0 +1,58 @@
+-- name: ClaimWebhookEvents :many
+UPDATE webhook_events
+SET status = 'processing', attempts = attempts + 1, next_attempt_at = sqlc.arg('lease_until')
+WHERE id IN (
+    SELECT id FROM webhook_events
+    WHERE status IN ('pending', 'processing') AND next_attempt_at <= NOW()
+    ORDER BY next_attempt_at
+    LIMIT sqlc.arg('batch_size')
+    FOR UPDATE SKIP LOCKED
+)
+RETURNING *;
+
+-- name: CreateWebhookEvent :one
+INSERT INTO webhook_events (source, event_id, event_type, headers, body)
+VALUES (
+    $1,
+    $2,
+    $3,
+    $4,
+    $5
+)
+ON CONFLICT (source, event_id) DO NOTHING
+RETURNING *;
+
+-- name: GetWebhookEvent :one
+SELECT * FROM webhook_events
+WHERE id = $1;
+
+-- name: ListWebhookEvents :many
+SELECT * FROM webhook_events
+WHERE (sqlc.narg('status')::text IS NULL OR status = sqlc.narg('status')::text)
+AND (
+    sqlc.narg('after_received_at')::timestamp IS NULL
+    OR (received_at, id) < (sqlc.narg('after_received_at')::timestamp, sqlc.narg('after_id')::uuid)
+)
+ORDER BY received_at DESC, id DESC
+LIMIT sqlc.arg('page_limit');
+
+-- name: MarkWebhookEventFailed :exec
+UPDATE webhook_events
+SET status = 'failed', last_error = $2, processed_at = NOW()
+WHERE id = $1;
+
+-- name: MarkWebhookEventSucceeded :exec
+UPDATE webhook_events
+SET status = 'succeeded', last_error = NULL, processed_at = NOW()
+WHERE id = $1;
+
+-- name: ReplayWebhookEvent :one
+UPDATE webhook_events
+SET status = 'pending', attempts = 0, next_attempt_at = NOW(), processed_at = NULL
+WHERE id = $1 AND status = 'failed'
+RETURNING *;
+
+-- name: RetryWebhookEvent :exec
+UPDATE webhook_events
+SET status = 'pending', last_error = $2, next_attempt_at = $3
+WHERE id = $1;
@@ -0,0 +1,16 @@
+-- +goose Up
+-- in_reply_to deliberately has no foreign key: replies outlive a deleted
+-- parent. reply_path lists every ancestor from the thread root down to the
+-- direct parent so a thread can still be assembled around the gap.
+ALTER TABLE chirps
+ADD COLUMN in_reply_to UUID,
+ADD COLUMN reply_path UUID[] NOT NULL DEFAULT '{}';
+CREATE INDEX chirps_in_reply_to_idx ON chirps (in_reply_to);
+CREATE INDEX chirps_reply_path_idx ON chirps USING GIN (reply_path);
+
+-- +goose Down
+DROP INDEX chirps_reply_path_idx;
+DROP INDEX chirps_in_reply_to_idx;
+ALTER TABLE chirps
+DROP COLUMN reply_path,
+DROP COLUMN in_reply_to;
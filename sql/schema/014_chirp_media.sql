@@ -0,0 +1,16 @@
+-- +goose Up
+CREATE TABLE chirp_media(
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    created_at TIMESTAMP NOT NULL DEFAULT now(),
+    chirp_id UUID NOT NULL,
+    storage_key TEXT NOT NULL UNIQUE,
+    content_type TEXT NOT NULL,
+    size_bytes BIGINT NOT NULL,
+    FOREIGN KEY (chirp_id)
+    REFERENCES chirps(id)
+    ON DELETE CASCADE
+);
+CREATE INDEX chirp_media_chirp_id_idx ON chirp_media (chirp_id);
+
+-- +goose Down
+DROP TABLE chirp_media;
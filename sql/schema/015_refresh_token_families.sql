@@ -0,0 +1,14 @@
+-- +goose Up
+-- Every login starts a token family. Each refresh rotates the presented
+-- token (rotated_at) and issues a new one in the same family, so a rotated
+-- token showing up again means it was copied and the family is revoked.
+ALTER TABLE refresh_tokens
+ADD COLUMN family_id UUID NOT NULL DEFAULT gen_random_uuid(),
+ADD COLUMN rotated_at TIMESTAMP;
+CREATE INDEX refresh_tokens_family_id_idx ON refresh_tokens (family_id);
+
+-- +goose Down
+DROP INDEX refresh_tokens_family_id_idx;
+ALTER TABLE refresh_tokens
+DROP COLUMN rotated_at,
+DROP COLUMN family_id;
@@ -0,0 +1,30 @@
+-- +goose Up
+-- One row per throttled key: "email:<address>", "ip:<address>" or
+-- "mfa:<user id>". Rows are kept in the database rather than in memory so
+-- the limits hold across restarts and instances.
+CREATE TABLE login_throttles(
+    throttle_key TEXT PRIMARY KEY,
+    failures INTEGER NOT NULL DEFAULT 0,
+    last_failure_at TIMESTAMP NOT NULL DEFAULT now(),
+    locked_until TIMESTAMP
+);
+
+CREATE TABLE lockout_events(
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID,
+    throttle_key TEXT NOT NULL,
+    ip_address TEXT NOT NULL,
+    failures INTEGER NOT NULL,
+    locked_until TIMESTAMP NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT now(),
+    unlocked_at TIMESTAMP,
+    FOREIGN KEY (user_id)
+    REFERENCES users(id)
+    ON DELETE CASCADE
+);
+CREATE INDEX lockout_events_created_at_idx ON lockout_events (created_at DESC);
+CREATE INDEX lockout_events_user_id_idx ON lockout_events (user_id);
+
+-- +goose Down
+DROP TABLE lockout_events;
+DROP TABLE login_throttles;
@@ -0,0 +1,10 @@
+-- +goose Up
+-- IDs of Polka webhook events already handled, so a replayed request is
+-- ignored even when its signature is still valid.
+CREATE TABLE polka_events(
+    event_id TEXT PRIMARY KEY,
+    received_at TIMESTAMP NOT NULL DEFAULT now()
+);
+
+-- +goose Down
+DROP TABLE polka_events;
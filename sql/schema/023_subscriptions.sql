@@ -0,0 +1,31 @@
+-- +goose Up
+-- users.is_chirpy_red stays as the flag the rest of the app reads; it is
+-- kept in step with the subscription row by the webhook handler and the
+-- expiry job. current_period_end is NULL only for subscriptions whose
+-- period is not known yet; the expiry job leaves those alone.
+CREATE TABLE subscriptions(
+    user_id UUID PRIMARY KEY,
+    plan TEXT NOT NULL,
+    status TEXT NOT NULL
+    CHECK (status IN ('active', 'past_due', 'canceled', 'expired')),
+    current_period_end TIMESTAMP,
+    cancel_at_period_end BOOLEAN NOT NULL DEFAULT false,
+    created_at TIMESTAMP NOT NULL DEFAULT now(),
+    updated_at TIMESTAMP NOT NULL DEFAULT now(),
+    FOREIGN KEY (user_id)
+    REFERENCES users(id)
+    ON DELETE CASCADE
+);
+CREATE INDEX subscriptions_period_end_idx ON subscriptions (current_period_end)
+WHERE status <> 'expired';
+
+-- Users upgraded before subscriptions existed paid for a period we were
+-- never told, so they keep Red until Polka next renews, cancels or
+-- downgrades them rather than losing it to a guessed end date.
+INSERT INTO subscriptions (user_id, plan, status, current_period_end)
+SELECT id, 'red', 'active', NULL
+FROM users
+WHERE is_chirpy_red;
+
+-- +goose Down
+DROP TABLE subscriptions;
@@ -0,0 +1,39 @@
+-- +goose Up
+-- Every authenticated inbound webhook, kept so it can be processed in the
+-- background, retried, inspected and replayed. It replaces polka_events:
+-- the (source, event_id) constraint is what now drops replayed events.
+CREATE TABLE webhook_events(
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    source TEXT NOT NULL,
+    event_id TEXT,
+    event_type TEXT NOT NULL,
+    headers JSONB NOT NULL,
+    body TEXT NOT NULL,
+    status TEXT NOT NULL DEFAULT 'pending'
+    CHECK (status IN ('pending', 'processing', 'succeeded', 'failed')),
+    attempts INTEGER NOT NULL DEFAULT 0,
+    last_error TEXT,
+    received_at TIMESTAMP NOT NULL DEFAULT now(),
+    next_attempt_at TIMESTAMP NOT NULL DEFAULT now(),
+    processed_at TIMESTAMP,
+    UNIQUE (source, event_id)
+);
+CREATE INDEX webhook_events_due_idx ON webhook_events (next_attempt_at)
+WHERE status IN ('pending', 'processing');
+CREATE INDEX webhook_events_received_at_idx ON webhook_events (received_at DESC, id DESC);
+
+INSERT INTO webhook_events (source, event_id, event_type, headers, body, status, received_at, processed_at)
+SELECT 'polka', event_id, '', '{}', '', 'succeeded', received_at, received_at
+FROM polka_events;
+DROP TABLE polka_events;
+
+-- +goose Down
+CREATE TABLE polka_events(
+    event_id TEXT PRIMARY KEY,
+    received_at TIMESTAMP NOT NULL DEFAULT now()
+);
+INSERT INTO polka_events (event_id, received_at)
+SELECT event_id, received_at
+FROM webhook_events
+WHERE source = 'polka' AND event_id IS NOT NULL;
+DROP TABLE webhook_events;
@@ -0,0 +1,10 @@
+-- +goose Up
+-- last_event_at is when the newest Polka event applied to the row was
+-- received. Older events are ignored, so a retried or replayed event cannot
+-- undo a later one. Rows from before this column accept any event.
+ALTER TABLE subscriptions
+ADD COLUMN last_event_at TIMESTAMP;
+
+-- +goose Down
+ALTER TABLE subscriptions
+DROP COLUMN last_event_at;
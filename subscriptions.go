@@ -0,0 +1,168 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/A-X-Z-Y-T-E/Chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+const (
+	defaultSubscriptionPlan = "red"
+	// defaultSubscriptionPeriod is used when Polka does not send a
+	// period_end, as older webhook payloads do not.
+	defaultSubscriptionPeriod  = 30 * 24 * time.Hour
+	subscriptionExpiryInterval = time.Minute
+)
+
+var errUnknownSubscriber = errors.New("unknown subscriber")
+
+// polkaEvent is the body of a Polka webhook.
+type polkaEvent struct {
+	ID    string `json:"id"`
+	Event string `json:"event"`
+	Data  struct {
+		UserID    string     `json:"user_id"`
+		Plan      string     `json:"plan"`
+		PeriodEnd *time.Time `json:"period_end"`
+	} `json:"data"`
+}
+
+// applyPolkaEvent moves the user's subscription through its lifecycle and
+// keeps users.is_chirpy_red in step with it:
+//
+//   - user.upgraded and subscription.renewed start or extend a period.
+//   - subscription.payment_failed and subscription.canceled leave Red in
+//     place until the paid period runs out; expireSubscriptions ends it.
+//     A canceled subscription with no known period ends right away.
+//   - user.downgraded ends Red immediately.
+//
+// Other events are ignored, as are events received before the last one
+// applied to the subscription, so retries and replays cannot undo newer
+// ones. A renewal never shortens the paid period. errUnknownSubscriber means
+// the event named a user, or a subscription, that does not exist.
+func (cfg *apiConfig) applyPolkaEvent(ctx context.Context, event polkaEvent, receivedAt time.Time) error {
+	switch event.Event {
+	case "user.upgraded", "subscription.renewed",
+		"subscription.payment_failed", "subscription.canceled",
+		"user.downgraded":
+	default:
+		return nil
+	}
+	userID, err := uuid.Parse(event.Data.UserID)
+	if err != nil {
+		return errUnknownSubscriber
+	}
+
+	switch event.Event {
+	case "user.upgraded", "subscription.renewed":
+		plan := event.Data.Plan
+		if plan == "" {
+			plan = defaultSubscriptionPlan
+		}
+		periodEnd := time.Now().Add(defaultSubscriptionPeriod)
+		if event.Data.PeriodEnd != nil {
+			periodEnd = *event.Data.PeriodEnd
+		}
+		sub, err := cfg.db.UpsertSubscription(ctx, database.UpsertSubscriptionParams{
+			UserID:           userID,
+			Plan:             plan,
+			CurrentPeriodEnd: sql.NullTime{Time: periodEnd.UTC(), Valid: true},
+			EventAt:          receivedAt,
+		})
+		if isForeignKeyViolation(err) {
+			return errUnknownSubscriber
+		}
+		if errors.Is(err, sql.ErrNoRows) {
+			// A newer event has already been applied.
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err := cfg.db.UpgradeUserToChirpyRed(ctx, userID); err != nil {
+			return err
+		}
+		if event.Event == "user.upgraded" {
+			cfg.emitEvent(ctx, eventUserUpgraded, struct {
+				User_id            string `json:"user_id"`
+				Plan               string `json:"plan"`
+				Current_period_end string `json:"current_period_end"`
+			}{
+				User_id:            sub.UserID.String(),
+				Plan:               sub.Plan,
+				Current_period_end: sub.CurrentPeriodEnd.Time.UTC().Format(time.RFC3339Nano),
+			})
+		}
+	case "subscription.payment_failed":
+		n, err := cfg.db.MarkSubscriptionPastDue(ctx, database.MarkSubscriptionPastDueParams{
+			EventAt: receivedAt,
+			UserID:  userID,
+		})
+		if err != nil {
+			return err
+		}
+		if n == 0 {
+			return cfg.subscriptionUnchanged(ctx, userID)
+		}
+	case "subscription.canceled":
+		n, err := cfg.db.CancelSubscription(ctx, database.CancelSubscriptionParams{
+			EventAt: receivedAt,
+			UserID:  userID,
+		})
+		if err != nil {
+			return err
+		}
+		if n == 0 {
+			return cfg.subscriptionUnchanged(ctx, userID)
+		}
+	case "user.downgraded":
+		n, err := cfg.db.EndSubscription(ctx, database.EndSubscriptionParams{
+			EventAt: receivedAt,
+			UserID:  userID,
+		})
+		if err != nil {
+			return err
+		}
+		if n == 0 {
+			// Users upgraded before subscriptions existed may have no row
+			// and are still downgraded; a row that was left alone has
+			// seen a newer event.
+			err := cfg.subscriptionUnchanged(ctx, userID)
+			if !errors.Is(err, errUnknownSubscriber) {
+				return err
+			}
+		}
+		return cfg.db.DowngradeUserFromChirpyRed(ctx, userID)
+	}
+	return nil
+}
+
+// subscriptionUnchanged explains an event that updated no subscription:
+// errUnknownSubscriber if the user has none, nil if it is expired or has
+// already seen a newer event.
+func (cfg *apiConfig) subscriptionUnchanged(ctx context.Context, userID uuid.UUID) error {
+	_, err := cfg.db.GetSubscription(ctx, userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return errUnknownSubscriber
+	}
+	return err
+}
+
+// expireSubscriptions takes Red away from every user whose paid period has
+// ended without a renewal. It runs in the background every
+// subscriptionExpiryInterval.
+func (cfg *apiConfig) expireSubscriptions(ctx context.Context) error {
+	expired, err := cfg.db.ExpireSubscriptions(ctx)
+	if err != nil {
+		return err
+	}
+	if len(expired) > 0 {
+		fmt.Printf("expired %d Chirpy Red subscriptions\n", len(expired))
+	}
+	return nil
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/A-X-Z-Y-T-E/Chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+// Upper bound on how many replies a single thread request will load. Longer
+// threads come back with Truncated set, and the reply_count of each chirp
+// still counts every reply.
+const maxThreadReplies = 500
+
+// ThreadChirp is a chirp inside a thread. A chirp that was deleted while it
+// still had replies is kept as a placeholder with only ID and Deleted set.
+type ThreadChirp struct {
+	Chirp
+	Deleted    bool           `json:"deleted,omitempty"`
+	ReplyCount int64          `json:"reply_count"`
+	Replies    []*ThreadChirp `json:"replies,omitempty"`
+}
+
+type Thread struct {
+	Ancestors []*ThreadChirp `json:"ancestors"`
+	Chirp     *ThreadChirp   `json:"chirp"`
+	Truncated bool           `json:"truncated"`
+}
+
+func deletedThreadChirp(id uuid.UUID) *ThreadChirp {
+	return &ThreadChirp{Chirp: Chirp{ID: id.String()}, Deleted: true}
+}
+
+func (cfg *apiConfig) GetThread() http.Handler {
+	return http.HandlerFunc(func(resW http.ResponseWriter, req *http.Request) {
+		resW.Header().Set("Content-Type", "application/json")
+
+		chirpID, err := convert_to_uuid(req.PathValue("chirpID"))
+		if err != nil {
+			resW.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(resW).Encode(struct {
+				Error string `json:"error"`
+			}{
+				Error: "Invalid chirp ID",
+			})
+			return
+		}
+		chirp, err := cfg.db.GetChirpByID(req.Context(), chirpID)
+		if err != nil {
+			resW.WriteHeader(http.StatusNotFound)
+			json.NewEncoder(resW).Encode(struct {
+				Error string `json:"error"`
+			}{
+				Error: "Chirp not found",
+			})
+			return
+		}
+
+		ancestors, err := cfg.db.GetChirpsByIDs(req.Context(), chirp.ReplyPath)
+		if err != nil {
+			resW.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(resW).Encode(struct {
+				Error string `json:"error"`
+			}{
+				Error: "Internal Server Error.",
+			})
+			return
+		}
+		descendants, err := cfg.db.GetChirpDescendants(req.Context(), database.GetChirpDescendantsParams{
+			ChirpID:   chirp.ID,
+			PageLimit: maxThreadReplies + 1,
+		})
+		if err != nil {
+			resW.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(resW).Encode(struct {
+				Error string `json:"error"`
+			}{
+				Error: "Internal Server Error.",
+			})
+			return
+		}
+
+		nodes := map[uuid.UUID]*ThreadChirp{}
+		for _, ancestor := range ancestors {
+			nodes[ancestor.ID] = &ThreadChirp{Chirp: chirpJSON(ancestor)}
+		}
+		thread := Thread{Ancestors: []*ThreadChirp{}}
+		if len(descendants) > maxThreadReplies {
+			descendants = descendants[:maxThreadReplies]
+			thread.Truncated = true
+		}
+		for _, id := range chirp.ReplyPath {
+			node, ok := nodes[id]
+			if !ok {
+				node = deletedThreadChirp(id)
+				nodes[id] = node
+			}
+			thread.Ancestors = append(thread.Ancestors, node)
+		}
+
+		thread.Chirp = &ThreadChirp{Chirp: chirpJSON(chirp)}
+		nodes[chirp.ID] = thread.Chirp
+
+		// Descendants come back oldest first, so a surviving parent is always
+		// in nodes before its replies. Anything missing along the path was
+		// deleted and gets a placeholder.
+		depth := len(chirp.ReplyPath) + 1
+		for _, reply := range descendants {
+			parent := thread.Chirp
+			for _, id := range reply.ReplyPath[depth:] {
+				node, ok := nodes[id]
+				if !ok {
+					node = deletedThreadChirp(id)
+					nodes[id] = node
+					parent.Replies = append(parent.Replies, node)
+				}
+				parent = node
+			}
+			node := &ThreadChirp{Chirp: chirpJSON(reply)}
+			nodes[reply.ID] = node
+			parent.Replies = append(parent.Replies, node)
+		}
+
+		ids := make([]uuid.UUID, 0, len(nodes))
+		for id := range nodes {
+			ids = append(ids, id)
+		}
+		counts, err := cfg.db.CountReplies(req.Context(), ids)
+		if err != nil {
+			resW.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(resW).Encode(struct {
+				Error string `json:"error"`
+			}{
+				Error: "Internal Server Error.",
+			})
+			return
+		}
+		for _, count := range counts {
+			nodes[count.ChirpID].ReplyCount = count.ReplyCount
+		}
+		live := map[uuid.UUID]*Chirp{}
+		for id, node := range nodes {
+			if !node.Deleted {
+				live[id] = &node.Chirp
+			}
+		}
+		if err := cfg.decorateChirps(req.Context(), cfg.viewerID(req), live); err != nil {
+			resW.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(resW).Encode(struct {
+				Error string `json:"error"`
+			}{
+				Error: "Internal Server Error.",
+			})
+			return
+		}
+
+		resW.WriteHeader(http.StatusOK)
+		json.NewEncoder(resW).Encode(thread)
+	})
+}
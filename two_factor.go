@@ -0,0 +1,358 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/A-X-Z-Y-T-E/Chirpy/internal/auth"
+	"github.com/A-X-Z-Y-T-E/Chirpy/internal/database"
+	"github.com/A-X-Z-Y-T-E/Chirpy/internal/mailer"
+	"github.com/google/uuid"
+)
+
+const (
+	mfaChallengeTTL   = 5 * time.Minute
+	recoveryCodeCount = 10
+)
+
+// mfaChallenge answers the password step of a two-factor login. The token
+// only proves the password was right; LoginTwoFactor trades it plus a code
+// for real tokens.
+func (cfg *apiConfig) mfaChallenge(w http.ResponseWriter, user database.User) {
+	w.Header().Set("Content-Type", "application/json")
+	token, err := cfg.keys.MakeJWT(user.ID, auth.WithAudience(auth.AudienceMFA), auth.WithTTL(mfaChallengeTTL))
+	if err != nil {
+		fmt.Println("MakeJWT error:", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(struct {
+		MFARequired bool   `json:"mfa_required"`
+		MFAToken    string `json:"mfa_token"`
+	}{
+		MFARequired: true,
+		MFAToken:    token,
+	})
+}
+
+// checkSecondFactor accepts either a current TOTP code or an unused recovery
+// code. Both are single-use.
+func (cfg *apiConfig) checkSecondFactor(r *http.Request, totp database.UserTotp, code, recoveryCode string) bool {
+	if code != "" {
+		step, ok := auth.ValidateTOTP(totp.Secret, code, time.Now())
+		if !ok {
+			return false
+		}
+		n, err := cfg.db.UseTOTPStep(r.Context(), database.UseTOTPStepParams{
+			UserID:   totp.UserID,
+			LastStep: step,
+		})
+		return err == nil && n == 1
+	}
+	if recoveryCode == "" {
+		return false
+	}
+	recoveryCode = auth.NormalizeRecoveryCode(recoveryCode)
+	codes, err := cfg.db.ListUnusedRecoveryCodes(r.Context(), totp.UserID)
+	if err != nil {
+		fmt.Println("ListUnusedRecoveryCodes error:", err)
+		return false
+	}
+	for _, stored := range codes {
+		ok, err := auth.CheckPasswordHash(recoveryCode, stored.CodeHash)
+		if err != nil || !ok {
+			continue
+		}
+		n, err := cfg.db.UseRecoveryCode(r.Context(), stored.ID)
+		return err == nil && n == 1
+	}
+	return false
+}
+
+func (cfg *apiConfig) LoginTwoFactor() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		type parameters struct {
+			MFAToken     string `json:"mfa_token"`
+			Code         string `json:"code"`
+			RecoveryCode string `json:"recovery_code"`
+		}
+		var params parameters
+		err := json.NewDecoder(r.Body).Decode(&params)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		token, err := cfg.keys.ParseJWT(params.MFAToken, auth.RequireAudience(auth.AudienceMFA))
+		if err != nil {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		// Six digits fall to guessing quickly, so codes are throttled per
+		// account just like passwords.
+		attempt, ok := cfg.startLoginAttempt(w, r, accountThrottle(mfaThrottleKey(token.UserID)), ipThrottle(r))
+		if !ok {
+			return
+		}
+		user, err := cfg.db.GetUserFromId(r.Context(), token.UserID)
+		if err != nil {
+			cfg.loginFailed(r, attempt, nil)
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		totp, err := cfg.db.GetUserTOTP(r.Context(), user.ID)
+		if err != nil || !totp.ConfirmedAt.Valid {
+			cfg.loginFailed(r, attempt, &user)
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if !cfg.checkSecondFactor(r, totp, params.Code, params.RecoveryCode) {
+			cfg.loginFailed(r, attempt, &user)
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		cfg.loginSucceeded(r, attempt)
+		cfg.startSession(w, r, user)
+	})
+}
+
+// EnrollTwoFactor starts TOTP enrollment. Two-factor login only switches on
+// once ConfirmTwoFactor has seen a code from the new secret, so a user who
+// abandons enrollment halfway is not locked out.
+func (cfg *apiConfig) EnrollTwoFactor() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		user, _ := auth.UserFromContext(r.Context())
+		if totp, err := cfg.db.GetUserTOTP(r.Context(), user.ID); err == nil && totp.ConfirmedAt.Valid {
+			w.WriteHeader(http.StatusConflict)
+			json.NewEncoder(w).Encode(struct {
+				Error string `json:"error"`
+			}{
+				Error: "Two-factor authentication is already enabled",
+			})
+			return
+		}
+		secret, err := auth.GenerateTOTPSecret()
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(struct {
+				Error string `json:"error"`
+			}{
+				Error: "Something went wrong",
+			})
+			return
+		}
+		err = cfg.db.UpsertUserTOTP(r.Context(), database.UpsertUserTOTPParams{
+			UserID: user.ID,
+			Secret: secret,
+		})
+		if err != nil {
+			fmt.Println("UpsertUserTOTP error:", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(struct {
+				Error string `json:"error"`
+			}{
+				Error: "Something went wrong",
+			})
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(struct {
+			Secret     string `json:"secret"`
+			OtpauthURI string `json:"otpauth_uri"`
+		}{
+			Secret:     secret,
+			OtpauthURI: auth.TOTPURI("Chirpy", user.Email, secret),
+		})
+	})
+}
+
+// ConfirmTwoFactor enables two-factor login and returns the recovery codes.
+// They are shown only this once; only their hashes are kept. The current
+// password is required too, so a stolen access token alone cannot put the
+// account behind someone else's authenticator, and the owner is told by
+// email either way.
+func (cfg *apiConfig) ConfirmTwoFactor() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		user, _ := auth.UserFromContext(r.Context())
+		type parameters struct {
+			Code     string `json:"code"`
+			Password string `json:"password"`
+		}
+		var params parameters
+		err := json.NewDecoder(r.Body).Decode(&params)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(struct {
+				Error string `json:"error"`
+			}{
+				Error: "Invalid request body",
+			})
+			return
+		}
+		// Wrong passwords count towards the same lockout as logins, or this
+		// would be a way around it.
+		attempt, ok := cfg.startLoginAttempt(w, r, accountThrottle(emailThrottleKey(user.Email)))
+		if !ok {
+			return
+		}
+		if ok, err := auth.CheckPasswordHash(params.Password, user.HashedPassword); err != nil || !ok {
+			cfg.loginFailed(r, attempt, &user)
+			w.WriteHeader(http.StatusUnauthorized)
+			json.NewEncoder(w).Encode(struct {
+				Error string `json:"error"`
+			}{
+				Error: "Incorrect password",
+			})
+			return
+		}
+		cfg.loginSucceeded(r, attempt)
+		totp, err := cfg.db.GetUserTOTP(r.Context(), user.ID)
+		if err != nil || totp.ConfirmedAt.Valid {
+			w.WriteHeader(http.StatusConflict)
+			json.NewEncoder(w).Encode(struct {
+				Error string `json:"error"`
+			}{
+				Error: "No two-factor enrollment in progress",
+			})
+			return
+		}
+		if !cfg.checkSecondFactor(r, totp, params.Code, "") {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(struct {
+				Error string `json:"error"`
+			}{
+				Error: "Invalid code",
+			})
+			return
+		}
+
+		codes, err := cfg.replaceRecoveryCodes(r, user.ID)
+		if err != nil {
+			fmt.Println("replaceRecoveryCodes error:", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(struct {
+				Error string `json:"error"`
+			}{
+				Error: "Something went wrong",
+			})
+			return
+		}
+		if err := cfg.db.ConfirmUserTOTP(r.Context(), user.ID); err != nil {
+			fmt.Println("ConfirmUserTOTP error:", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(struct {
+				Error string `json:"error"`
+			}{
+				Error: "Something went wrong",
+			})
+			return
+		}
+		cfg.sendMail(mailer.Message{
+			To:      user.Email,
+			Subject: "Two-factor authentication was turned on for your Chirpy account",
+			Body: fmt.Sprintf("Two-factor authentication was turned on for your Chirpy account from %s.\n\n"+
+				"If this wasn't you, reset your password and contact support.\n", clientIP(r)),
+		})
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(struct {
+			RecoveryCodes []string `json:"recovery_codes"`
+		}{
+			RecoveryCodes: codes,
+		})
+	})
+}
+
+func (cfg *apiConfig) replaceRecoveryCodes(r *http.Request, userID uuid.UUID) ([]string, error) {
+	codes, err := auth.GenerateRecoveryCodes(recoveryCodeCount)
+	if err != nil {
+		return nil, err
+	}
+	if err := cfg.db.DeleteRecoveryCodes(r.Context(), userID); err != nil {
+		return nil, err
+	}
+	for _, code := range codes {
+		hash, err := auth.HashPassword(code)
+		if err != nil {
+			return nil, err
+		}
+		err = cfg.db.CreateRecoveryCode(r.Context(), database.CreateRecoveryCodeParams{
+			UserID:   userID,
+			CodeHash: hash,
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return codes, nil
+}
+
+// DisableTwoFactor turns two-factor login off. It takes a code as well as
+// the access token so a stolen token alone cannot remove the second factor,
+// and codes are throttled as in LoginTwoFactor so that one cannot be guessed.
+func (cfg *apiConfig) DisableTwoFactor() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		user, _ := auth.UserFromContext(r.Context())
+		type parameters struct {
+			Code         string `json:"code"`
+			RecoveryCode string `json:"recovery_code"`
+		}
+		var params parameters
+		err := json.NewDecoder(r.Body).Decode(&params)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(struct {
+				Error string `json:"error"`
+			}{
+				Error: "Invalid request body",
+			})
+			return
+		}
+		totp, err := cfg.db.GetUserTOTP(r.Context(), user.ID)
+		if err != nil || !totp.ConfirmedAt.Valid {
+			w.WriteHeader(http.StatusNotFound)
+			json.NewEncoder(w).Encode(struct {
+				Error string `json:"error"`
+			}{
+				Error: "Two-factor authentication is not enabled",
+			})
+			return
+		}
+		attempt, ok := cfg.startLoginAttempt(w, r, accountThrottle(mfaThrottleKey(user.ID)), ipThrottle(r))
+		if !ok {
+			return
+		}
+		if !cfg.checkSecondFactor(r, totp, params.Code, params.RecoveryCode) {
+			cfg.loginFailed(r, attempt, &user)
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(struct {
+				Error string `json:"error"`
+			}{
+				Error: "Invalid code",
+			})
+			return
+		}
+		cfg.loginSucceeded(r, attempt)
+		if err := cfg.db.DeleteUserTOTP(r.Context(), user.ID); err != nil {
+			fmt.Println("DeleteUserTOTP error:", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(struct {
+				Error string `json:"error"`
+			}{
+				Error: "Something went wrong",
+			})
+			return
+		}
+		if err := cfg.db.DeleteRecoveryCodes(r.Context(), user.ID); err != nil {
+			fmt.Println("DeleteRecoveryCodes error:", err)
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
@@ -0,0 +1,317 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/A-X-Z-Y-T-E/Chirpy/internal/database"
+	"github.com/A-X-Z-Y-T-E/Chirpy/internal/jobs"
+	"github.com/A-X-Z-Y-T-E/Chirpy/internal/pagination"
+)
+
+const (
+	webhookSourcePolka = "polka"
+
+	webhookPollInterval = 30 * time.Second
+	webhookBatchSize    = 10
+	// webhookLease is how long a claimed event may stay in processing
+	// before another worker takes it over, e.g. after a crash.
+	webhookLease       = 5 * time.Minute
+	webhookMaxAttempts = 8
+)
+
+var webhookBackoff = jobs.Backoff{Base: 30 * time.Second, Max: time.Hour}
+
+var webhookStatuses = map[string]bool{
+	"pending":    true,
+	"processing": true,
+	"succeeded":  true,
+	"failed":     true,
+}
+
+type WebhookEvent struct {
+	ID            string          `json:"id"`
+	Source        string          `json:"source"`
+	EventID       string          `json:"event_id,omitempty"`
+	EventType     string          `json:"event_type"`
+	Status        string          `json:"status"`
+	Attempts      int32           `json:"attempts"`
+	LastError     string          `json:"last_error,omitempty"`
+	ReceivedAt    string          `json:"received_at"`
+	NextAttemptAt string          `json:"next_attempt_at,omitempty"`
+	ProcessedAt   string          `json:"processed_at,omitempty"`
+	Headers       json.RawMessage `json:"headers,omitempty"`
+	Body          string          `json:"body,omitempty"`
+}
+
+type webhookEventPage struct {
+	Events     []WebhookEvent `json:"events"`
+	NextCursor string         `json:"next_cursor,omitempty"`
+}
+
+// webhookEventJSON leaves out the headers and body unless detail is set, so
+// listings stay small.
+func webhookEventJSON(event database.WebhookEvent, detail bool) WebhookEvent {
+	out := WebhookEvent{
+		ID:         event.ID.String(),
+		Source:     event.Source,
+		EventID:    event.EventID.String,
+		EventType:  event.EventType,
+		Status:     event.Status,
+		Attempts:   event.Attempts,
+		LastError:  event.LastError.String,
+		ReceivedAt: event.ReceivedAt.String(),
+	}
+	if event.Status == "pending" {
+		out.NextAttemptAt = event.NextAttemptAt.String()
+	}
+	if event.ProcessedAt.Valid {
+		out.ProcessedAt = event.ProcessedAt.Time.String()
+	}
+	if detail {
+		out.Headers = event.Headers
+		out.Body = event.Body
+	}
+	return out
+}
+
+// webhookHeaders is what gets stored of a request's headers. Credentials
+// are left out.
+func webhookHeaders(h http.Header) json.RawMessage {
+	kept := http.Header{}
+	for name, values := range h {
+		if name == "Authorization" || name == "Cookie" {
+			continue
+		}
+		kept[name] = values
+	}
+	data, err := json.Marshal(kept)
+	if err != nil {
+		return json.RawMessage("{}")
+	}
+	return data
+}
+
+// recordWebhookEvent stores an authenticated webhook for the background
+// worker. It reports false when (source, eventID) has been seen before.
+func (cfg *apiConfig) recordWebhookEvent(r *http.Request, source, eventID, eventType string, body []byte) (bool, error) {
+	_, err := cfg.db.CreateWebhookEvent(r.Context(), database.CreateWebhookEventParams{
+		Source:    source,
+		EventID:   sql.NullString{String: eventID, Valid: eventID != ""},
+		EventType: eventType,
+		Headers:   webhookHeaders(r.Header),
+		Body:      string(body),
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	cfg.webhookTrigger.Fire()
+	return true, nil
+}
+
+// processWebhookEvents works through every event that is due. It runs in
+// the background every webhookPollInterval and whenever an event arrives.
+func (cfg *apiConfig) processWebhookEvents(ctx context.Context) error {
+	for {
+		events, err := cfg.db.ClaimWebhookEvents(ctx, database.ClaimWebhookEventsParams{
+			LeaseUntil: time.Now().Add(webhookLease),
+			BatchSize:  webhookBatchSize,
+		})
+		if err != nil {
+			return err
+		}
+		if len(events) == 0 {
+			return nil
+		}
+		for _, event := range events {
+			cfg.processWebhookEvent(ctx, event)
+		}
+	}
+}
+
+func (cfg *apiConfig) processWebhookEvent(ctx context.Context, event database.WebhookEvent) {
+	err := cfg.handleWebhookEvent(ctx, event)
+	if err == nil {
+		if err := cfg.db.MarkWebhookEventSucceeded(ctx, event.ID); err != nil {
+			fmt.Println("MarkWebhookEventSucceeded error:", err)
+		}
+		return
+	}
+
+	lastError := sql.NullString{String: err.Error(), Valid: true}
+	// Retrying cannot make an unknown user appear.
+	if errors.Is(err, errUnknownSubscriber) || event.Attempts >= webhookMaxAttempts {
+		fmt.Printf("webhook event %s failed: %v\n", event.ID, err)
+		err = cfg.db.MarkWebhookEventFailed(ctx, database.MarkWebhookEventFailedParams{
+			ID:        event.ID,
+			LastError: lastError,
+		})
+		if err != nil {
+			fmt.Println("MarkWebhookEventFailed error:", err)
+		}
+		return
+	}
+	err = cfg.db.RetryWebhookEvent(ctx, database.RetryWebhookEventParams{
+		ID:            event.ID,
+		LastError:     lastError,
+		NextAttemptAt: time.Now().Add(webhookBackoff.Delay(int(event.Attempts))),
+	})
+	if err != nil {
+		fmt.Println("RetryWebhookEvent error:", err)
+	}
+}
+
+func (cfg *apiConfig) handleWebhookEvent(ctx context.Context, event database.WebhookEvent) error {
+	switch event.Source {
+	case webhookSourcePolka:
+		var polka polkaEvent
+		if err := json.Unmarshal([]byte(event.Body), &polka); err != nil {
+			return err
+		}
+		return cfg.applyPolkaEvent(ctx, polka, event.ReceivedAt)
+	}
+	return fmt.Errorf("unknown webhook source %q", event.Source)
+}
+
+// ListWebhookEvents lists received webhooks, newest first, optionally only
+// those with ?status=.
+func (cfg *apiConfig) ListWebhookEvents() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		page, err := pagination.FromQuery(r.URL.Query())
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(struct {
+				Error string `json:"error"`
+			}{
+				Error: err.Error(),
+			})
+			return
+		}
+		status := r.URL.Query().Get("status")
+		if status != "" && !webhookStatuses[status] {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(struct {
+				Error string `json:"error"`
+			}{
+				Error: "Invalid status",
+			})
+			return
+		}
+
+		events, err := cfg.db.ListWebhookEvents(r.Context(), database.ListWebhookEventsParams{
+			Status:          sql.NullString{String: status, Valid: status != ""},
+			AfterReceivedAt: page.AfterCreatedAt,
+			AfterID:         page.AfterID,
+			PageLimit:       page.Limit + 1,
+		})
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(struct {
+				Error string `json:"error"`
+			}{
+				Error: "Internal Server Error.",
+			})
+			return
+		}
+
+		out := webhookEventPage{Events: []WebhookEvent{}}
+		if len(events) > int(page.Limit) {
+			events = events[:page.Limit]
+			last := events[len(events)-1]
+			out.NextCursor = pagination.EncodeCursor(last.ReceivedAt, last.ID)
+		}
+		for _, event := range events {
+			out.Events = append(out.Events, webhookEventJSON(event, false))
+		}
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(out)
+	})
+}
+
+// GetWebhookEvent shows one webhook with the headers and body it arrived
+// with.
+func (cfg *apiConfig) GetWebhookEvent() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		EventID, err := convert_to_uuid(r.PathValue("eventID"))
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(struct {
+				Error string `json:"error"`
+			}{
+				Error: "Invalid event ID",
+			})
+			return
+		}
+		event, err := cfg.db.GetWebhookEvent(r.Context(), EventID)
+		if err != nil {
+			w.WriteHeader(http.StatusNotFound)
+			json.NewEncoder(w).Encode(struct {
+				Error string `json:"error"`
+			}{
+				Error: "Webhook event not found",
+			})
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(webhookEventJSON(event, true))
+	})
+}
+
+// ReplayWebhookEvent queues a failed webhook to be processed again, with a
+// fresh set of attempts.
+func (cfg *apiConfig) ReplayWebhookEvent() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		EventID, err := convert_to_uuid(r.PathValue("eventID"))
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(struct {
+				Error string `json:"error"`
+			}{
+				Error: "Invalid event ID",
+			})
+			return
+		}
+		event, err := cfg.db.ReplayWebhookEvent(r.Context(), EventID)
+		if errors.Is(err, sql.ErrNoRows) {
+			// Either there is no such event or it has not failed.
+			status, msg := http.StatusConflict, "Only failed webhook events can be replayed"
+			if _, err := cfg.db.GetWebhookEvent(r.Context(), EventID); err != nil {
+				status, msg = http.StatusNotFound, "Webhook event not found"
+			}
+			w.WriteHeader(status)
+			json.NewEncoder(w).Encode(struct {
+				Error string `json:"error"`
+			}{
+				Error: msg,
+			})
+			return
+		}
+		if err != nil {
+			fmt.Println("ReplayWebhookEvent error:", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(struct {
+				Error string `json:"error"`
+			}{
+				Error: "Something went wrong",
+			})
+			return
+		}
+		cfg.webhookTrigger.Fire()
+		w.WriteHeader(http.StatusAccepted)
+		json.NewEncoder(w).Encode(webhookEventJSON(event, false))
+	})
+}